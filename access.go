@@ -0,0 +1,55 @@
+package ringbuf
+
+type sliceRequest struct {
+	from, to   int64
+	responseCh chan sliceResponse
+}
+
+type sliceResponse struct {
+	items []Item
+	err   error
+}
+
+// Returns the item with sequence number seq if it is still retained,
+// otherwise ErrOverwritten or ErrNotWritten.
+func (r *Ringbuf) At(seq int64) (interface{}, error) {
+	items, err := r.Slice(seq, seq+1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		return nil, ErrOverwritten
+	}
+
+	return items[0].Data, nil
+}
+
+// Returns the items with sequence numbers from from up to, but not
+// including, to. Fails with ErrOverwritten if from is not retained any
+// more, or with ErrNotWritten if to is past the last write. Superseded
+// items of a compacting ringbuf are left out.
+func (r *Ringbuf) Slice(from, to int64) ([]Item, error) {
+	req := &sliceRequest{from: from, to: to, responseCh: make(chan sliceResponse, 1)}
+
+	if err := r.send(newData(ringbufStatusSlice, req)); err != nil {
+		return nil, err
+	}
+
+	resp := <-req.responseCh
+	return resp.items, resp.err
+}
+
+// Unsafe. Must be called by IO main loop.
+func (r *Ringbuf) slice(from, to int64) sliceResponse {
+	switch {
+	case from < r.oldest():
+		return sliceResponse{err: ErrOverwritten}
+	case to > r.written():
+		return sliceResponse{err: ErrNotWritten}
+	case from >= to:
+		return sliceResponse{}
+	}
+
+	return sliceResponse{items: r.items(from, to)}
+}
@@ -0,0 +1,54 @@
+package ringbuf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRingbufAt(t *testing.T) {
+	ring := NewRingbuf(3)
+	go ring.Run()
+
+	for i := 0; i < 5; i++ {
+		ring.Write(fmt.Sprintf("test%d", i))
+	}
+
+	if data, err := ring.At(3); err != nil || data != "test3" {
+		t.Error(fmt.Sprintf("Expected 'test3', got '%v' %v", data, err))
+	}
+
+	if _, err := ring.At(1); !errors.Is(err, ErrOverwritten) {
+		t.Error(fmt.Sprintf("Expected ErrOverwritten, got %v", err))
+	}
+
+	if _, err := ring.At(5); !errors.Is(err, ErrNotWritten) {
+		t.Error(fmt.Sprintf("Expected ErrNotWritten, got %v", err))
+	}
+
+	items, err := ring.Slice(2, 5)
+	if err != nil || len(items) != 3 || items[0].Seq != 2 || items[2].Data != "test4" {
+		t.Error(fmt.Sprintf("Unexpected slice %v %v", items, err))
+	}
+
+	ring.Cancel()
+}
+
+func TestRingbufAtCompact(t *testing.T) {
+	ring := NewRingbufCompact(10, keyBeforeEquals)
+	go ring.Run()
+
+	ring.Write("a=1")
+	ring.Write("b=1")
+	ring.Write("a=2")
+
+	if _, err := ring.At(0); !errors.Is(err, ErrOverwritten) {
+		t.Error(fmt.Sprintf("Expected superseded item to be overwritten, got %v", err))
+	}
+
+	if items, _ := ring.Slice(0, 3); len(items) != 2 {
+		t.Error(fmt.Sprintf("Expected 2 items, got %v", items))
+	}
+
+	ring.Cancel()
+}
@@ -0,0 +1,142 @@
+package ringbuf
+
+import "time"
+
+// Time after which unacknowledged items are delivered again,
+// unless the reader sets its own AckTimeout.
+const DefaultAckTimeout = 30 * time.Second
+
+// Acknowledge the item with sequence number seq. Only meaningful
+// for readers in Ack mode.
+func (r *Reader) Ack(seq int64) error {
+	return r.ring.send(newDataSeq(ringbufStatusAck, r, seq))
+}
+
+func (r *Reader) ackTimeout() time.Duration {
+	if r.options().AckTimeout > 0 {
+		return r.options().AckTimeout
+	}
+
+	return DefaultAckTimeout
+}
+
+// Unsafe. Must be called by IO main loop. Returns the time to wait
+// before the next redelivery is due, or nil if nothing is unacknowledged.
+func (r *Reader) nextRedelivery() interface{} {
+	if len(r.unacked) == 0 {
+		return nil
+	}
+
+	var first time.Time
+
+	for _, due := range r.unacked {
+		if first.IsZero() || due.Before(first) {
+			first = due
+		}
+	}
+
+	wait := first.Sub(time.Now())
+	if wait < 0 {
+		wait = 0
+	}
+
+	return wait
+}
+
+// Unsafe. Must be called by IO main loop. Serve again the oldest
+// unacknowledged item that is due for redelivery.
+func (r *Ringbuf) redeliver(reader *Reader) (Data, bool) {
+	now := time.Now()
+
+	for len(reader.unacked) > 0 {
+		seq := int64(-1)
+
+		for s, due := range reader.unacked {
+			if !due.After(now) && (seq < 0 || s < seq) {
+				seq = s
+			}
+		}
+
+		if seq < 0 {
+			break
+		}
+
+		// Overwritten in the meantime, there is nothing to redeliver.
+		if seq < r.oldest() {
+			delete(reader.unacked, seq)
+			continue
+		}
+
+		reader.unacked[seq] = now.Add(reader.ackTimeout())
+		return newDataSeq(ringbufStatusOK, r.data[seq%r.size], seq), true
+	}
+
+	return Data{}, false
+}
+
+// Unsafe. Must be called by IO main loop. Returns true if the next
+// write would overwrite an item that a lossless reader still needs.
+func (r *Ringbuf) full() bool {
+	if r.written() < r.size {
+		return false
+	}
+
+	seq := r.written() - r.size
+
+	for reader := range r.readersStarving {
+		if !reader.options().Lossless || r.readersCanceled[reader] {
+			continue
+		}
+
+		if reader.seq() <= seq {
+			return true
+		}
+
+		if _, ok := reader.unacked[seq]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Unsafe. Must be called by IO main loop. Perform the writes that
+// were held back, as long as there is room for them.
+func (r *Ringbuf) unpark() {
+	for len(r.parked) > 0 && !r.full() {
+		msg := r.parked[0]
+		r.parked[0], r.parked = Data{}, r.parked[1:]
+
+		r.write(writeData(msg))
+		confirmWrite(msg, true)
+
+		r.wakeupStarving()
+	}
+}
+
+// Unsafe. Must be called by IO main loop. Fail the writes that were
+// held back, nothing will make room for them any more.
+func (r *Ringbuf) rejectParked() {
+	for i := range r.parked {
+		confirmWrite(r.parked[i], false)
+		r.parked[i] = Data{}
+	}
+
+	r.parked = nil
+}
+
+// The data of a write message.
+func writeData(msg Data) interface{} {
+	if msg.status == ringbufStatusWriteWait {
+		return msg.data.(*Write).data
+	}
+
+	return msg.data
+}
+
+// Tell the writer waiting for msg, if any, whether it was written.
+func confirmWrite(msg Data, ok bool) {
+	if msg.status == ringbufStatusWriteWait {
+		msg.data.(*Write).responseCh <- ok
+	}
+}
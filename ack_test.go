@@ -0,0 +1,140 @@
+package ringbuf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAckRedeliver(t *testing.T) {
+	ring := NewRingbuf(10)
+	reader := NewReader(ring, WithAck(10*time.Millisecond))
+	itemCh := reader.ReadItemCh()
+
+	go ring.Run()
+
+	ring.Write("test0")
+	ring.Write("test1")
+	ring.EOF()
+
+	first := <-itemCh
+	if first.Seq != 0 || first.Data != "test0" {
+		t.Error(fmt.Sprintf("Expected 'test0' at 0, got '%s' at %d", first.Data, first.Seq))
+	}
+
+	second := <-itemCh
+	if second.Seq != 1 || second.Data != "test1" {
+		t.Error(fmt.Sprintf("Expected 'test1' at 1, got '%s' at %d", second.Data, second.Seq))
+	}
+
+	reader.Ack(second.Seq)
+
+	// The first item was never acknowledged.
+	again := <-itemCh
+	if again.Seq != 0 || again.Data != "test0" {
+		t.Error(fmt.Sprintf("Expected 'test0' again, got '%s' at %d", again.Data, again.Seq))
+	}
+
+	reader.Ack(again.Seq)
+
+	if _, ok := <-itemCh; ok {
+		t.Error("Expected EOF after all items are acknowledged")
+	}
+
+	ring.Cancel()
+}
+
+func TestAckLossless(t *testing.T) {
+	ring := NewRingbuf(2)
+	reader := NewReader(ring, WithAck(0), WithLossless())
+	itemCh := reader.ReadItemCh()
+
+	go ring.Run()
+
+	ring.Write("test0")
+
+	item := <-itemCh
+
+	ring.Write("test1")
+
+	written := make(chan bool)
+	go func() {
+		// Would overwrite the unacknowledged first item.
+		ring.Write("test2")
+		written <- true
+	}()
+
+	select {
+	case <-written:
+		t.Error("Write overwrote an unacknowledged item")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	reader.Ack(item.Seq)
+	<-written
+
+	for _, exp := range []string{"test1", "test2"} {
+		item = <-itemCh
+		if item.Data != exp {
+			t.Error(fmt.Sprintf("Expected '%s', got '%s'", exp, item.Data))
+		}
+		reader.Ack(item.Seq)
+	}
+
+	reader.Cancel()
+	<-itemCh
+	ring.Cancel()
+}
+
+func TestLosslessParkedEOF(t *testing.T) {
+	ring := NewRingbuf(2)
+	reader := NewReader(ring, WithAck(0), WithLossless())
+	itemCh := reader.ReadItemCh()
+
+	go ring.Run()
+
+	ring.Write("test0")
+	first := <-itemCh
+	ring.Write("test1")
+
+	errCh := make(chan error)
+	for _, data := range []string{"test2", "test3"} {
+		go func(data string) {
+			// Held back by the unacknowledged first item.
+			errCh <- ring.Write(data)
+		}(data)
+	}
+
+	select {
+	case err := <-errCh:
+		t.Fatal(fmt.Sprintf("Expected the writes to be held back, got %v", err))
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	ring.EOF()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errCh:
+			if err != ErrClosed {
+				t.Error(fmt.Sprintf("Expected ErrClosed, got %v", err))
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Held back writes were not failed after EOF")
+		}
+	}
+
+	reader.Ack(first.Seq)
+
+	if item := <-itemCh; item.Data != "test1" {
+		t.Error(fmt.Sprintf("Expected 'test1', got '%s'", item.Data))
+	} else {
+		reader.Ack(item.Seq)
+	}
+
+	if _, ok := <-itemCh; ok {
+		t.Error("Expected EOF after all items")
+	}
+
+	ring.Cancel()
+}
@@ -1,6 +1,10 @@
 package ringbuf
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"time"
+)
 
 // Implement reader and writer interface for []byte
 
@@ -22,16 +26,19 @@ func (rb *Bytes) Write(b []byte) (int, error) {
 	data := make([]byte, len(b))
 	copy(data, b)
 
-	rb.r.Write(data)
+	if err := rb.r.Write(data); err != nil {
+		return 0, err
+	}
+
 	return len(data), nil
 }
 
-func (rb *Bytes) Close() {
-	rb.r.Cancel()
+func (rb *Bytes) Close() error {
+	return rb.r.Cancel()
 }
 
-func (rb *Bytes) EOF() {
-	rb.r.EOF()
+func (rb *Bytes) EOF() error {
+	return rb.r.EOF()
 }
 
 func (rb *Bytes) Ringbuf() *Ringbuf {
@@ -39,9 +46,10 @@ func (rb *Bytes) Ringbuf() *Ringbuf {
 }
 
 type ReaderBytes struct {
-	rb    *Reader
-	ch    <-chan interface{}
-	isEOF bool
+	rb       *Reader
+	ch       <-chan interface{}
+	isEOF    bool
+	deadline time.Time
 }
 
 func NewReaderBytes(r *Bytes) *ReaderBytes {
@@ -52,27 +60,58 @@ func NewReaderBytes(r *Bytes) *ReaderBytes {
 	}
 }
 
+// Set the time after which Read() fails with ErrTimeout. A zero
+// value means Read() waits forever. Must not be called during a Read().
+func (r *ReaderBytes) SetReadDeadline(t time.Time) error {
+	r.deadline = t
+	return nil
+}
+
 func (r *ReaderBytes) Read(p []byte) (bread int, err error) {
 	if r.isEOF {
-		return
+		return 0, r.err()
 	}
 
+	var data interface{}
+
 	// This will block until there is unread data to read.
-	data := <-r.ch
+	if r.deadline.IsZero() {
+		data = <-r.ch
+	} else {
+		timer := time.NewTimer(r.deadline.Sub(time.Now()))
+		defer timer.Stop()
+
+		select {
+		case data = <-r.ch:
+		case <-timer.C:
+			return 0, ErrTimeout
+		}
+	}
 
 	if bytes, ok := data.([]byte); ok {
 		bread = len(bytes)
 		size := len(p)
 
 		if bread > size {
-			err = fmt.Errorf("Given size %d is too small. Read %d bytes.", size, bread)
+			err = fmt.Errorf("%w: given size %d is too small, read %d bytes", ErrShortBuffer, size, bread)
 			bread = size
 		}
 
 		copy(p, bytes)
 	} else {
 		r.isEOF = true
+		err = r.err()
 	}
 
 	return
 }
+
+// Why reading has stopped: io.EOF at the end of data, otherwise
+// the same as Reader.Err().
+func (r *ReaderBytes) err() error {
+	if err := r.rb.Err(); err != nil {
+		return err
+	}
+
+	return io.EOF
+}
@@ -1,8 +1,12 @@
 package ringbuf
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"testing"
+	"time"
 )
 
 func _testBytesWriter(t *testing.T, writer *Bytes) {
@@ -25,7 +29,7 @@ func _testBytesWriter(t *testing.T, writer *Bytes) {
 		data := make([]byte, 100)
 		smallbuf := make([]byte, 2)
 
-		if n, err := reader.Read(smallbuf); n != 2 && err != nil {
+		if n, err := reader.Read(smallbuf); n != 2 || !errors.Is(err, ErrShortBuffer) {
 			t.Error("Expected error because buffer is too small")
 		}
 
@@ -33,19 +37,21 @@ func _testBytesWriter(t *testing.T, writer *Bytes) {
 
 		for {
 			// Blocks here until new data is read.
-			if n, err := reader.Read(data); err == nil {
-				if n == 0 {
-					break
-				}
-
-				str := string(data[0:n])
-				exp := fmt.Sprintf("Some data %d", i)
-
-				if str != exp {
-					t.Error(fmt.Sprintf("Unexpected read from ringbuf.Reader(): expected '%s', got '%s'", exp, data))
-				}
-			} else {
+			n, err := reader.Read(data)
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
 				t.Error(fmt.Sprintf("Invalid read in ringbuf.Reader(): error: %s", err))
+				break
+			}
+
+			str := string(data[0:n])
+			exp := fmt.Sprintf("Some data %d", i)
+
+			if str != exp {
+				t.Error(fmt.Sprintf("Unexpected read from ringbuf.Reader(): expected '%s', got '%s'", exp, data))
 			}
 
 			i++
@@ -72,3 +78,29 @@ func TestBytesWriter(t *testing.T) {
 	writer := NewBytes(ring)
 	_testBytesWriter(t, writer)
 }
+
+func TestReaderBytesDeadline(t *testing.T) {
+	writer := NewRingbufBytes(10)
+	reader := NewReaderBytes(writer)
+
+	go writer.Ringbuf().Run()
+
+	reader.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+
+	data := make([]byte, 100)
+
+	_, err := reader.Read(data)
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Error(fmt.Sprintf("Expected timeout error, got %v", err))
+	}
+
+	writer.Write([]byte("Some data"))
+	reader.SetReadDeadline(time.Time{})
+
+	if n, err := reader.Read(data); err != nil || string(data[:n]) != "Some data" {
+		t.Error(fmt.Sprintf("Unexpected read after timeout: %d bytes, error %v", n, err))
+	}
+
+	writer.EOF()
+	writer.Close()
+}
@@ -0,0 +1,74 @@
+package ringbuf
+
+import "sort"
+
+// Returns the key of an item. Items with a nil key have no key.
+type KeyFunc func(data interface{}) interface{}
+
+// Create a Ringbuf that only retains the newest item for each key.
+// New readers first get the newest item of each key still in the
+// ringbuf, then follow new writes.
+func NewRingbufCompact(size int64, key KeyFunc) *Ringbuf {
+	return NewRingbuf(size, WithCompaction(key))
+}
+
+// Unsafe. Must be called by IO main loop after data was written as seq.
+func (r *Ringbuf) compact(seq int64, data interface{}) {
+	r.superseded[seq%r.size] = false
+
+	key := r.key(data)
+	if key == nil {
+		return
+	}
+
+	// The older item for the same key is skipped by readers from now on.
+	if old, ok := r.latest[key]; ok && old.Seq >= r.oldest() {
+		r.superseded[old.Seq%r.size] = true
+	}
+
+	r.latest[key] = Item{Seq: seq, Data: data}
+}
+
+// Unsafe. Must be called by IO main loop before the item seq is
+// overwritten. A key whose newest item is gone is not kept any more.
+func (r *Ringbuf) forget(seq int64) {
+	key := r.key(r.data[seq%r.size])
+	if key == nil {
+		return
+	}
+
+	if item, ok := r.latest[key]; ok && item.Seq == seq {
+		delete(r.latest, key)
+	}
+}
+
+// Unsafe. Must be called by IO main loop. Returns the newest
+// item of each key, oldest first.
+func (r *Ringbuf) snapshot() []Item {
+	items := make([]Item, 0, len(r.latest))
+
+	for _, item := range r.latest {
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Seq < items[j].Seq
+	})
+
+	return items
+}
+
+// Unsafe. Must be called by IO main loop. Returns the next item
+// of the reader's snapshot that has not been superseded since.
+func (r *Ringbuf) nextSnapshot(reader *Reader) (Item, bool) {
+	for len(reader.snapshot) > 0 {
+		item := reader.snapshot[0]
+		reader.snapshot[0], reader.snapshot = Item{}, reader.snapshot[1:]
+
+		if latest, ok := r.latest[r.key(item.Data)]; ok && latest.Seq == item.Seq {
+			return item, true
+		}
+	}
+
+	return Item{}, false
+}
@@ -0,0 +1,72 @@
+package ringbuf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func keyBeforeEquals(data interface{}) interface{} {
+	return strings.SplitN(data.(string), "=", 2)[0]
+}
+
+func TestCompactSkipsSuperseded(t *testing.T) {
+	ring := NewRingbufCompact(10, keyBeforeEquals)
+	reader := NewReader(ring)
+
+	// Start reading before anything is written.
+	if _, ok := ring.serve(reader); ok {
+		t.Error("Expected read fail")
+	}
+
+	ring.write("a=0")
+	ring.write("b=0")
+	ring.write("a=1")
+
+	for _, exp := range []string{"b=0", "a=1"} {
+		if msg, ok := ring.serve(reader); !ok || msg.data != exp {
+			t.Error(fmt.Sprintf("Expected value %s, got '%s'", exp, msg.data))
+		}
+	}
+
+	if _, ok := ring.serve(reader); ok {
+		t.Error("Expected read fail")
+	}
+}
+
+func TestCompactSnapshot(t *testing.T) {
+	ring := NewRingbufCompact(3, keyBeforeEquals)
+
+	ring.write("a=0")
+	ring.write("b=0")
+	ring.write("c=0")
+	ring.write("a=1")
+	ring.write("d=0")
+
+	// The item for "b" has been overwritten, its key is forgotten.
+	reader := NewReader(ring)
+
+	for _, exp := range []string{"c=0", "a=1", "d=0"} {
+		if msg, ok := ring.serve(reader); !ok || msg.data != exp {
+			t.Error(fmt.Sprintf("Expected value %s, got '%s'", exp, msg.data))
+		}
+	}
+
+	ring.write("b=1")
+
+	if msg, ok := ring.serve(reader); !ok || msg.data != "b=1" {
+		t.Error(fmt.Sprintf("Expected value b=1, got '%s'", msg.data))
+	}
+}
+
+func TestCompactForgetsOverwritten(t *testing.T) {
+	ring := NewRingbufCompact(3, keyBeforeEquals)
+
+	for i := 0; i < 100; i++ {
+		ring.write(fmt.Sprintf("key%d=0", i))
+	}
+
+	if len(ring.latest) != 3 {
+		t.Error(fmt.Sprintf("Expected 3 keys, got %d", len(ring.latest)))
+	}
+}
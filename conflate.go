@@ -0,0 +1,220 @@
+package ringbuf
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// An item waiting to be delivered by a conflating reader.
+type conflated struct {
+	key interface{}
+	msg Data
+}
+
+// Add msg to the pending items, replacing the one with the same key.
+func (r *Reader) conflate(pending []*conflated, keys map[interface{}]*conflated, msg Data) []*conflated {
+	var key interface{}
+	if f := r.options().Conflate; f != nil {
+		key = f(msg.data)
+	}
+
+	if c, ok := keys[key]; ok && key != nil {
+		// The replaced item will never be delivered, nothing to wait for.
+		if r.options().Ack {
+			r.Ack(c.msg.seq)
+		}
+
+		c.msg = msg
+		atomic.AddInt64(&r.conflated, 1)
+		atomic.AddInt64(&r.buffered, -1)
+
+		return pending
+	}
+
+	c := &conflated{key: key, msg: msg}
+	if key != nil {
+		keys[key] = c
+	}
+
+	return append(pending, c)
+}
+
+// Drop the pending items received before a seek.
+func (r *Reader) dropStale(pending []*conflated, keys map[interface{}]*conflated) []*conflated {
+	fresh := pending[:0]
+
+	for _, c := range pending {
+		if !r.stale(c.msg) {
+			fresh = append(fresh, c)
+			continue
+		}
+
+		if keys[c.key] == c {
+			delete(keys, c.key)
+		}
+	}
+
+	for i := len(fresh); i < len(pending); i++ {
+		pending[i] = nil
+	}
+
+	r.withdraw(len(pending) - len(fresh))
+
+	return fresh
+}
+
+// Read from the ringbuf as fast as possible, but deliver to the user
+// only as fast as it reads. Items pending in the meantime are conflated.
+// At most as many items as the ringbuf holds are pending: when they are
+// all different, reading waits for the user.
+func (r *Reader) runConflate(items bool) {
+	var (
+		pending  []*conflated
+		keys     = make(map[interface{}]*conflated)
+		starving bool
+		done     bool
+		timer    *time.Timer
+		active   = time.Now() // When the last item was received
+	)
+
+	readCh, itemCh := r.readCh, r.itemCh
+	if items {
+		readCh = nil
+	} else {
+		itemCh = nil
+	}
+
+	for {
+		// Not passing anything to the user, see settle().
+		atomic.StoreInt64(&r.offering, 0)
+
+		full := int64(len(pending)) >= r.ring.size
+
+		if !starving && !done && !full {
+			msg := r.request()
+
+			switch msg.status {
+			case ringbufStatusOK, ringbufStatusBatch:
+				active = time.Now()
+
+				for _, msg := range received(msg) {
+					pending = r.conflate(pending, keys, msg)
+				}
+			case ringbufStatusEOF:
+				done = true
+			case ringbufStatusStarving:
+				if r.options().NoStarve {
+					done = true
+					break
+				}
+
+				if timer != nil {
+					timer.Stop()
+				}
+
+				starving = true
+				timer = redeliveryTimer(msg)
+			}
+		}
+
+		if len(pending) == 0 {
+			if done {
+				r.leave()
+				return
+			}
+
+			if starving {
+				select {
+				case <-r.starving:
+				case <-timerCh(timer):
+				case <-r.idleCh(active):
+					r.timeout()
+					return
+				}
+
+				starving, timer = false, nil
+			}
+
+			continue
+		}
+
+		head := pending[0]
+		item := Item{Seq: head.msg.seq, Data: head.msg.data}
+
+		// The rate limit might not allow to deliver yet.
+		outCh, outItemCh := readCh, itemCh
+		var (
+			paceCh     <-chan time.Time
+			canceledCh <-chan struct{}
+		)
+
+		r.updateLimiter()
+
+		if r.limiter != nil {
+			if wait := r.limiter.delay(time.Now()); wait > 0 {
+				outCh, outItemCh = nil, nil
+				paceCh = time.After(wait)
+				canceledCh = r.canceled
+			}
+		}
+
+		if outCh != nil || outItemCh != nil {
+			// Offered before looking at the seeks, see settle().
+			r.offer(item.Seq)
+
+			if r.stale(head.msg) {
+				pending = r.dropStale(pending, keys)
+				continue
+			}
+		}
+
+		if starving || done || full {
+			var wakeupCh <-chan bool
+			if starving {
+				wakeupCh = r.starving
+			}
+
+			// Nothing new to read, or no room for it: wait for the user
+			// or for new data.
+			select {
+			case outCh <- item.Data:
+			case outItemCh <- item:
+			case <-paceCh:
+				continue
+			case <-r.probeCh:
+				continue
+			case <-canceledCh:
+				// The pending items will never be delivered.
+				atomic.AddInt64(&r.buffered, -int64(len(pending)))
+				pending, keys = nil, make(map[interface{}]*conflated)
+				starving = false
+				continue
+			case <-wakeupCh:
+				starving = false
+				continue
+			case <-timerCh(timer):
+				starving, timer = false, nil
+				continue
+			}
+		} else {
+			// Deliver if the user is ready, otherwise go on reading.
+			select {
+			case outCh <- item.Data:
+			case outItemCh <- item:
+			default:
+				continue
+			}
+		}
+
+		if r.limiter != nil {
+			r.limiter.take(time.Now())
+		}
+
+		r.passed(item.Seq)
+
+		pending[0], pending = nil, pending[1:]
+		if keys[head.key] == head {
+			delete(keys, head.key)
+		}
+	}
+}
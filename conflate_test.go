@@ -0,0 +1,77 @@
+package ringbuf
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConflate(t *testing.T) {
+	ring := NewRingbuf(10)
+	reader := NewReader(ring, WithConflate(keyBeforeEquals))
+	readCh := reader.ReadCh()
+
+	go ring.Run()
+
+	ring.Write("a=0")
+	ring.Write("b=0")
+	ring.Write("a=1")
+	ring.Write("a=2")
+	ring.EOF()
+
+	// Nobody is reading, wait until the reader has seen everything.
+	for atomic.LoadInt64(&reader.conflated) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if stats, _ := reader.Stats(); stats.Conflated != 2 || stats.Delivered != 4 || stats.Lag != 2 {
+		t.Error(fmt.Sprintf("Unexpected stats %+v", stats))
+	}
+
+	for _, exp := range []string{"a=2", "b=0"} {
+		if data := <-readCh; data != exp {
+			t.Error(fmt.Sprintf("Expected value %s, got '%s'", exp, data))
+		}
+	}
+
+	if data := <-readCh; data != nil {
+		t.Error(fmt.Sprintf("Unexpected read after EOF: '%s'", data))
+	}
+
+	ring.Cancel()
+}
+
+func TestConflateBounded(t *testing.T) {
+	ring := NewRingbuf(4)
+	reader := NewReader(ring, WithConflate(keyBeforeEquals))
+	readCh := reader.ReadCh()
+
+	go ring.Run()
+
+	for i := 0; i < 20; i++ {
+		ring.Write(fmt.Sprintf("key%d=0", i))
+	}
+
+	// Nobody is reading, give the reader time to read everything it can.
+	time.Sleep(20 * time.Millisecond)
+
+	if buffered := atomic.LoadInt64(&reader.buffered); buffered > 4 {
+		t.Error(fmt.Sprintf("Expected at most 4 pending items, got %d", buffered))
+	}
+
+	// The reader was lapped: after its pending items, it gets
+	// what is left in the ringbuf.
+	for i := 0; ; i++ {
+		if data := <-readCh; data == "key19=0" {
+			break
+		}
+
+		if i >= 8 {
+			t.Fatal("Expected to read the newest item")
+		}
+	}
+
+	reader.Cancel()
+	ring.Cancel()
+}
@@ -0,0 +1,54 @@
+package ringbuf
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// The Ringbuf is not running any more, or does not accept writes after EOF().
+	ErrClosed = errors.New("ringbuf: closed")
+	// The buffer passed to ReaderBytes.Read() is smaller than the item read.
+	ErrShortBuffer = errors.New("ringbuf: short buffer")
+	// The options passed to a constructor or Reconfigure() are not valid.
+	ErrInvalidOptions = errors.New("ringbuf: invalid options")
+	// The item has been overwritten or, in a compacting ringbuf, superseded.
+	ErrOverwritten = errors.New("ringbuf: item overwritten")
+	// The item has not been written yet.
+	ErrNotWritten = errors.New("ringbuf: item not written yet")
+	// Returned when no data arrived in time. It implements net.Error.
+	ErrTimeout error = &timeoutError{}
+)
+
+type timeoutError struct{}
+
+func (e *timeoutError) Error() string {
+	return "ringbuf: timeout waiting for data"
+}
+
+func (e *timeoutError) Timeout() bool {
+	return true
+}
+
+func (e *timeoutError) Temporary() bool {
+	return true
+}
+
+// The writer has overwritten items before a reader could read them.
+type ErrOverrun struct {
+	Dropped int64 // Number of items lost
+}
+
+func (e *ErrOverrun) Error() string {
+	return fmt.Sprintf("ringbuf: reader overrun, %d items dropped", e.Dropped)
+}
+
+// Any *ErrOverrun matches, regardless of the number of items dropped.
+func (e *ErrOverrun) Is(target error) bool {
+	_, ok := target.(*ErrOverrun)
+	return ok
+}
+
+func invalidOptions(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidOptions, reason)
+}
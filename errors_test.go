@@ -0,0 +1,74 @@
+package ringbuf
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestErrClosed(t *testing.T) {
+	ring := NewRingbuf(10)
+	done := make(chan bool)
+
+	go func() {
+		ring.Run()
+		done <- true
+	}()
+
+	ring.Cancel()
+	<-done
+
+	if err := ring.Write("test0"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed writing to a stopped ringbuf, got %v", err)
+	}
+
+	if err := ring.Cancel(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed canceling a stopped ringbuf, got %v", err)
+	}
+
+	reader := NewReader(ring)
+
+	if _, ok := <-reader.ReadCh(); ok {
+		t.Error("Expected reading from a stopped ringbuf to end")
+	}
+
+	if err := reader.Err(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed reading from a stopped ringbuf, got %v", err)
+	}
+}
+
+func TestErrClosedAfterEOF(t *testing.T) {
+	ring := NewRingbuf(10)
+	go ring.Run()
+
+	ring.Write("test0")
+	ring.EOF()
+
+	if err := ring.Write("test1"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed writing after EOF, got %v", err)
+	}
+
+	ring.Cancel()
+}
+
+func TestErrorsMatch(t *testing.T) {
+	var err error = &ErrOverrun{Dropped: 3}
+
+	if !errors.Is(err, &ErrOverrun{}) {
+		t.Error("Expected any overrun to match ErrOverrun")
+	}
+
+	var overrun *ErrOverrun
+	if !errors.As(err, &overrun) || overrun.Dropped != 3 {
+		t.Errorf("Expected an overrun of 3 items, got %v", err)
+	}
+
+	var netErr net.Error
+	if !errors.As(ErrTimeout, &netErr) || !netErr.Timeout() {
+		t.Error("Expected ErrTimeout to be a net.Error timeout")
+	}
+
+	if err := (&ReaderOptions{MaxBatch: -1}).validate(); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Expected ErrInvalidOptions, got %v", err)
+	}
+}
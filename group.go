@@ -0,0 +1,249 @@
+package ringbuf
+
+// A Group shares one Reader between many members. Each item read
+// from the ringbuf is delivered to exactly one member.
+type Group struct {
+	reader   *Reader
+	dataCh   chan Data
+	done     chan struct{}         // Closed when Run() returns
+	members  map[*GroupReader]bool // Value is true if the member is canceled.
+	left     map[*GroupReader]bool // Canceled before their first request.
+	waiting  []*GroupReader        // Members waiting for an item, oldest first.
+	pending  []interface{}         // Items to redeliver, oldest first.
+	eof      bool
+	canceled bool
+}
+
+// A GroupReader is a member of a Group.
+type GroupReader struct {
+	group    *Group
+	outputCh chan Data
+	cancelCh chan bool
+	readCh   chan interface{}
+}
+
+func NewGroup(r *Ringbuf) *Group {
+	return &Group{
+		reader:  NewReader(r),
+		dataCh:  make(chan Data),
+		done:    make(chan struct{}),
+		members: make(map[*GroupReader]bool),
+		left:    make(map[*GroupReader]bool),
+	}
+}
+
+// Create a new member. The member joins the group when ReadCh() is called.
+func (g *Group) NewReader() *GroupReader {
+	return &GroupReader{
+		group:    g,
+		outputCh: make(chan Data, 1),
+		cancelCh: make(chan bool),
+		readCh:   make(chan interface{}),
+	}
+}
+
+// Send a message to the main loop, unless it has returned.
+func (g *Group) send(msg Data) error {
+	select {
+	case g.dataCh <- msg:
+		return nil
+	case <-g.done:
+		return ErrClosed
+	}
+}
+
+// Cancel all members and stop reading from the ringbuf.
+func (g *Group) Cancel() error {
+	return g.send(newData(ringbufStatusEOF, nil))
+}
+
+// Serve an item to the first waiting member.
+func (g *Group) deliver(data interface{}) {
+	member := g.waiting[0]
+	g.waiting[0], g.waiting = nil, g.waiting[1:]
+
+	member.outputCh <- newData(ringbufStatusOK, data)
+}
+
+func (g *Group) removeWaiting(member *GroupReader) bool {
+	for i := range g.waiting {
+		if g.waiting[i] == member {
+			g.waiting = append(g.waiting[:i], g.waiting[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g *Group) cancelMember(member *GroupReader) {
+	canceled, ok := g.members[member]
+	if !ok {
+		// Not reading yet, nothing will ever leave for it. Only
+		// make sure it gets EOF if it starts reading later.
+		g.left[member] = true
+		return
+	}
+
+	if canceled {
+		return
+	}
+
+	g.members[member] = true
+
+	// A waiting member can be answered directly; any other member
+	// is holding an item and will give it back.
+	if g.removeWaiting(member) {
+		member.outputCh <- newData(ringbufStatusEOF, nil)
+	} else {
+		close(member.cancelCh)
+	}
+}
+
+func (g *Group) handleMessage(msg Data) {
+	switch msg.status {
+	case ringbufStatusEOF:
+		// The shared reader is already gone after EOF.
+		if !g.canceled && !g.eof {
+			g.reader.Cancel()
+		}
+
+		g.canceled = true
+
+		for member := range g.members {
+			g.cancelMember(member)
+		}
+	// A member requesting an item.
+	case ringbufStatusReader:
+		member := msg.data.(*GroupReader)
+
+		if _, ok := g.members[member]; !ok {
+			g.members[member] = g.left[member]
+			delete(g.left, member)
+		}
+
+		if g.canceled || g.members[member] {
+			member.outputCh <- newData(ringbufStatusEOF, nil)
+			return
+		}
+
+		if len(g.pending) > 0 {
+			data := g.pending[0]
+			g.pending[0], g.pending = nil, g.pending[1:]
+			member.outputCh <- newData(ringbufStatusOK, data)
+			return
+		}
+
+		if g.eof {
+			member.outputCh <- newData(ringbufStatusEOF, nil)
+			return
+		}
+
+		g.waiting = append(g.waiting, member)
+	// A canceled member returning the item it could not deliver.
+	case ringbufStatusRequeue:
+		if len(g.waiting) > 0 {
+			g.deliver(msg.data)
+		} else {
+			g.pending = append([]interface{}{msg.data}, g.pending...)
+		}
+	case ringbufStatusReaderRequestCancel:
+		g.cancelMember(msg.data.(*GroupReader))
+	// A member has left the group.
+	case ringbufStatusReaderCancel:
+		member := msg.data.(*GroupReader)
+		g.removeWaiting(member)
+		delete(g.members, member)
+
+		member.cleanup()
+	}
+}
+
+// Send the end of data to all waiting members.
+func (g *Group) wakeupWaiting() {
+	for _, member := range g.waiting {
+		member.outputCh <- newData(ringbufStatusEOF, nil)
+	}
+
+	g.waiting = nil
+}
+
+func (g *Group) Run() {
+	readCh := g.reader.ReadCh()
+
+	defer close(g.done)
+	defer func() {
+		// Unblock the shared reader until the ringbuf lets it go.
+		go func() {
+			for range readCh {
+			}
+		}()
+	}()
+
+	for {
+		// Pull from the ringbuf only when somebody is waiting for data.
+		var inputCh <-chan interface{}
+		if len(g.waiting) > 0 && !g.eof {
+			inputCh = readCh
+		}
+
+		select {
+		case data, ok := <-inputCh:
+			if !ok || data == nil {
+				g.eof = true
+				g.wakeupWaiting()
+				continue
+			}
+
+			g.deliver(data)
+		case msg := <-g.dataCh:
+			g.handleMessage(msg)
+
+			if g.canceled && len(g.members) == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (m *GroupReader) ReadCh() <-chan interface{} {
+	go func() {
+		defer func() {
+			// Without the group running, clean up on our own.
+			if err := m.group.send(newData(ringbufStatusReaderCancel, m)); err != nil {
+				m.cleanup()
+			}
+		}()
+
+		for {
+			if err := m.group.send(newData(ringbufStatusReader, m)); err != nil {
+				return
+			}
+
+			msg := <-m.outputCh
+			if msg.status != ringbufStatusOK {
+				return
+			}
+
+			select {
+			case m.readCh <- msg.data:
+			case <-m.cancelCh:
+				// The item was not delivered, give it to another member.
+				m.group.send(newData(ringbufStatusRequeue, msg.data))
+				return
+			}
+		}
+	}()
+
+	return m.readCh
+}
+
+// Leave the group. An item that was not yet delivered to this
+// member is redelivered to another one.
+func (m *GroupReader) Cancel() error {
+	return m.group.send(newData(ringbufStatusReaderRequestCancel, m))
+}
+
+func (m *GroupReader) cleanup() {
+	close(m.readCh)
+}
@@ -0,0 +1,136 @@
+package ringbuf
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroupSharesItems(t *testing.T) {
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+
+	ring := NewRingbuf(100)
+	group := NewGroup(ring)
+
+	go ring.Run()
+	go group.Run()
+
+	result := make(map[string]int)
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(readCh <-chan interface{}) {
+			for data := range readCh {
+				mux.Lock()
+				result[data.(string)]++
+				mux.Unlock()
+			}
+
+			wg.Done()
+		}(group.NewReader().ReadCh())
+	}
+
+	for i := 0; i < 50; i++ {
+		ring.Write(fmt.Sprintf("test%d", i))
+	}
+	ring.EOF()
+
+	wg.Wait()
+
+	if len(result) != 50 {
+		t.Error(fmt.Sprintf("Expected 50 different items, got %d", len(result)))
+	}
+
+	for data, n := range result {
+		if n != 1 {
+			t.Error(fmt.Sprintf("Item '%s' was delivered %d times", data, n))
+		}
+	}
+
+	group.Cancel()
+	ring.Cancel()
+}
+
+func TestGroupRedeliverOnCancel(t *testing.T) {
+	ring := NewRingbuf(10)
+	group := NewGroup(ring)
+
+	go ring.Run()
+	go group.Run()
+
+	first := group.NewReader()
+	firstCh := first.ReadCh()
+
+	ring.Write("test0")
+
+	// The first member never reads, its item must go to the second one.
+	first.Cancel()
+
+	second := group.NewReader()
+	secondCh := second.ReadCh()
+
+	if data := <-secondCh; data != "test0" {
+		t.Error(fmt.Sprintf("Expected 'test0', got '%s'", data))
+	}
+
+	if data := <-firstCh; data != nil {
+		t.Error(fmt.Sprintf("Unexpected read from canceled member: '%s'", data))
+	}
+
+	group.Cancel()
+
+	if data := <-secondCh; data != nil {
+		t.Error(fmt.Sprintf("Unexpected read from canceled group: '%s'", data))
+	}
+
+	ring.Cancel()
+}
+
+func TestGroupCancelIdleMember(t *testing.T) {
+	ring := NewRingbuf(10)
+	group := NewGroup(ring)
+
+	go ring.Run()
+
+	done := make(chan struct{})
+	go func() {
+		group.Run()
+		close(done)
+	}()
+
+	// Canceled before it ever reads.
+	member := group.NewReader()
+	member.Cancel()
+
+	group.Cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Group did not stop after Cancel()")
+	}
+
+	ring.Cancel()
+}
+
+func TestGroupReadAfterCancel(t *testing.T) {
+	ring := NewRingbuf(10)
+	group := NewGroup(ring)
+
+	go ring.Run()
+	go group.Run()
+
+	member := group.NewReader()
+	member.Cancel()
+
+	ring.Write("test0")
+
+	if data, ok := <-member.ReadCh(); ok {
+		t.Error(fmt.Sprintf("Unexpected read from canceled member: '%s'", data))
+	}
+
+	group.Cancel()
+	ring.Cancel()
+}
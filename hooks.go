@@ -0,0 +1,112 @@
+package ringbuf
+
+import "sync"
+
+// Functions called on events of a Ringbuf. They are called in order,
+// but outside of the main loop: they can use the Ringbuf freely.
+// Any of them can be nil.
+type Hooks struct {
+	OnReaderJoin  func(reader *Reader)
+	OnReaderLeave func(reader *Reader)
+	// A reader lost items because the writer overtook it.
+	OnOverrun func(reader *Reader, dropped int64)
+	// The writer started again from the beginning of the ringbuf.
+	OnWrap func()
+	OnEOF  func()
+	// An item is being overwritten and no reader is keeping it.
+	OnEvict func(data interface{})
+}
+
+// Calls hooks in their own goroutine, in order.
+type hookQueue struct {
+	mux     sync.Mutex
+	calls   []func()
+	running bool
+	readyCh chan bool
+}
+
+func (q *hookQueue) push(f func()) {
+	q.mux.Lock()
+	q.calls = append(q.calls, f)
+
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+
+	q.mux.Unlock()
+
+	select {
+	case q.readyCh <- true:
+	default:
+	}
+}
+
+func (q *hookQueue) flush() {
+	for {
+		q.mux.Lock()
+		calls := q.calls
+		q.calls = nil
+		q.mux.Unlock()
+
+		if len(calls) == 0 {
+			return
+		}
+
+		for _, f := range calls {
+			f()
+		}
+	}
+}
+
+func (q *hookQueue) run() {
+	for range q.readyCh {
+		q.flush()
+	}
+
+	q.flush()
+}
+
+// Warning: this is not a safe operation. Do not set the hooks
+// after starting Run(). Prefer WithHooks() in NewRingbuf().
+func (r *Ringbuf) SetHooks(hooks *Hooks) {
+	r.hooks = hooks
+}
+
+// Unsafe. Must be called by IO main loop.
+func (r *Ringbuf) onReaderJoin(reader *Reader) {
+	if r.hooks != nil && r.hooks.OnReaderJoin != nil {
+		f := r.hooks.OnReaderJoin
+		r.hookQueue.push(func() { f(reader) })
+	}
+}
+
+// Unsafe. Must be called by IO main loop.
+func (r *Ringbuf) onReaderLeave(reader *Reader) {
+	if r.hooks != nil && r.hooks.OnReaderLeave != nil {
+		f := r.hooks.OnReaderLeave
+		r.hookQueue.push(func() { f(reader) })
+	}
+}
+
+// Unsafe. Must be called by IO main loop.
+func (r *Ringbuf) onWrap() {
+	if r.hooks != nil && r.hooks.OnWrap != nil {
+		r.hookQueue.push(r.hooks.OnWrap)
+	}
+}
+
+// Unsafe. Must be called by IO main loop.
+func (r *Ringbuf) onEOF() {
+	if r.hooks != nil && r.hooks.OnEOF != nil {
+		r.hookQueue.push(r.hooks.OnEOF)
+	}
+}
+
+// Unsafe. Must be called by IO main loop.
+func (r *Ringbuf) onEvict(data interface{}) {
+	if r.hooks != nil && r.hooks.OnEvict != nil {
+		f := r.hooks.OnEvict
+		r.hookQueue.push(func() { f(data) })
+	}
+}
@@ -0,0 +1,51 @@
+package ringbuf
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	eventCh := make(chan string, 10)
+
+	ring := NewRingbuf(3)
+	ring.SetHooks(&Hooks{
+		OnReaderJoin: func(reader *Reader) {
+			eventCh <- "join"
+		},
+		OnReaderLeave: func(reader *Reader) {
+			eventCh <- "leave"
+		},
+		OnWrap: func() {
+			eventCh <- "wrap"
+		},
+		OnEOF: func() {
+			// Hooks can use the ringbuf they are called from.
+			ring.Write("ignored")
+			eventCh <- "eof"
+		},
+		OnEvict: func(data interface{}) {
+			eventCh <- fmt.Sprintf("evict %s", data)
+		},
+	})
+
+	go ring.Run()
+
+	for i := 0; i < 4; i++ {
+		ring.Write(fmt.Sprintf("test%d", i))
+	}
+
+	ring.EOF()
+
+	reader := NewReader(ring)
+	for range reader.ReadCh() {
+	}
+
+	for _, exp := range []string{"wrap", "evict test0", "eof", "join", "leave"} {
+		if event := <-eventCh; event != exp {
+			t.Error(fmt.Sprintf("Expected event '%s', got '%s'", exp, event))
+		}
+	}
+
+	ring.Cancel()
+}
@@ -0,0 +1,87 @@
+package ringbuf
+
+import "iter"
+
+// Yields items until EOF. Breaking out of the loop cancels the reader.
+func (r *Reader) All() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		readCh := r.ReadCh()
+
+		for data := range readCh {
+			if !yield(data) {
+				stopReading(r, readCh)
+				return
+			}
+		}
+	}
+}
+
+// Like All(), but every item comes with its sequence number.
+func (r *Reader) WithSeq() iter.Seq2[int64, interface{}] {
+	return func(yield func(int64, interface{}) bool) {
+		itemCh := r.ReadItemCh()
+
+		for item := range itemCh {
+			if !yield(item.Seq, item.Data) {
+				stopReading(r, itemCh)
+				return
+			}
+		}
+	}
+}
+
+// Cancel the reader and wait for its goroutine to finish.
+func stopReading[T any](r *Reader, ch <-chan T) {
+	r.Cancel()
+
+	for range ch {
+	}
+}
+
+type retainedRequest struct {
+	responseCh chan []Item
+}
+
+// Yields the items currently in the ringbuf, oldest first, without
+// reading them. Each loop sees the items retained when it starts.
+// Yields nothing if the ringbuf is not running.
+func (r *Ringbuf) Retained() iter.Seq2[int64, interface{}] {
+	return func(yield func(int64, interface{}) bool) {
+		items, err := r.retained()
+		if err != nil {
+			return
+		}
+
+		for _, item := range items {
+			if !yield(item.Seq, item.Data) {
+				return
+			}
+		}
+	}
+}
+
+func (r *Ringbuf) retained() ([]Item, error) {
+	req := &retainedRequest{responseCh: make(chan []Item, 1)}
+
+	if err := r.send(newData(ringbufStatusRetained, req)); err != nil {
+		return nil, err
+	}
+
+	return <-req.responseCh, nil
+}
+
+// Unsafe. Must be called by IO main loop. Returns the items between
+// from and to, excluding superseded slots.
+func (r *Ringbuf) items(from, to int64) []Item {
+	items := make([]Item, 0, to-from)
+
+	for seq := from; seq < to; seq++ {
+		if r.key != nil && r.superseded[seq%r.size] {
+			continue
+		}
+
+		items = append(items, Item{Seq: seq, Data: r.data[seq%r.size]})
+	}
+
+	return items
+}
@@ -0,0 +1,82 @@
+package ringbuf
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestReaderAll(t *testing.T) {
+	ring := NewRingbuf(10)
+	go ring.Run()
+
+	for i := 0; i < 5; i++ {
+		ring.Write(i)
+	}
+	ring.EOF()
+
+	i := 0
+	for data := range NewReader(ring).All() {
+		if data != i {
+			t.Error(fmt.Sprintf("Expected %d, got %v", i, data))
+		}
+		i++
+	}
+
+	if i != 5 {
+		t.Error(fmt.Sprintf("Expected 5 items, got %d", i))
+	}
+
+	ring.Cancel()
+}
+
+func TestReaderWithSeqBreak(t *testing.T) {
+	ring := NewRingbuf(10)
+	go ring.Run()
+
+	for i := 0; i < 5; i++ {
+		ring.Write(i)
+	}
+
+	reader := NewReader(ring)
+
+	for seq, data := range reader.WithSeq() {
+		if seq != int64(data.(int)) {
+			t.Error(fmt.Sprintf("Expected sequence %v, got %d", data, seq))
+		}
+
+		if seq == 2 {
+			break
+		}
+	}
+
+	// The reader has left, the ringbuf can quit.
+	ring.Cancel()
+
+	if err := ring.Write(5); err != ErrClosed {
+		t.Error(fmt.Sprintf("Expected the ringbuf to quit, got %v", err))
+	}
+}
+
+func TestRingbufRetained(t *testing.T) {
+	ring := NewRingbuf(3)
+	go ring.Run()
+
+	for i := 0; i < 5; i++ {
+		ring.Write(i)
+	}
+
+	var items []interface{}
+	for seq, data := range ring.Retained() {
+		if seq != int64(data.(int)) {
+			t.Error(fmt.Sprintf("Expected sequence %v, got %d", data, seq))
+		}
+
+		items = append(items, data)
+	}
+
+	if fmt.Sprint(items) != "[2 3 4]" {
+		t.Error(fmt.Sprintf("Expected [2 3 4], got %v", items))
+	}
+
+	ring.Cancel()
+}
@@ -0,0 +1,64 @@
+package multiplex
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dullgiulio/ringbuf"
+)
+
+// What Close() does to the ringbufs of a Mux.
+type CloseMode int
+
+const (
+	// Leave the ringbufs alone, like Cancel().
+	CloseOnly CloseMode = iota
+	// Call EOF() on all ringbufs: their readers finish reading and exit.
+	CloseEOF
+	// Call Cancel() on all ringbufs.
+	CloseCancel
+)
+
+// Stop the Mux once all queued items are written, then apply mode
+// to every registered ringbuf.
+func (m *Mux) Close(mode CloseMode) error {
+	return m.send(newMuxMessageClose(mode))
+}
+
+// Closed when Run() has returned.
+func (m *Mux) Done() <-chan struct{} {
+	return m.done
+}
+
+// Unsafe. Must be called by the Mux main loop. Returns all ringbufs
+// the Mux writes to.
+func (m *Mux) registered() []*ringbuf.Ringbuf {
+	rings := make([]*ringbuf.Ringbuf, 0, len(m.sinks))
+
+	for ring := range m.sinks {
+		rings = append(rings, ring)
+	}
+
+	return rings
+}
+
+// Apply the close mode to rings.
+func (m *Mux) closeRings(rings []*ringbuf.Ringbuf) error {
+	var errs []error
+
+	for _, ring := range rings {
+		var err error
+
+		switch m.closeMode {
+		case CloseEOF:
+			err = ring.EOF()
+		case CloseCancel:
+			err = ring.Cancel()
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: closing ringbuf %p: %w", m, ring, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -3,19 +3,24 @@ package multiplex
 import (
 	"fmt"
 	"github.com/dullgiulio/ringbuf"
+	"time"
 )
 
 const (
 	demuxMessageCancel = iota
 	demuxMessageAdd
 	demuxMessageRemove
+	demuxMessageMembers
+	demuxMessageFinished
 )
 
 type DemuxMessageType int
 
 type DemuxMessage struct {
-	msgType DemuxMessageType
-	reader  *DemuxReader
+	msgType   DemuxMessageType
+	reader    *DemuxReader
+	addedCh   chan error
+	membersCh chan []*DemuxReader
 }
 
 func newDemuxMessageCancel() DemuxMessage {
@@ -28,6 +33,14 @@ func newDemuxMessageAdd(reader *DemuxReader) DemuxMessage {
 	return DemuxMessage{
 		msgType: demuxMessageAdd,
 		reader:  reader,
+		addedCh: make(chan error, 1),
+	}
+}
+
+func newDemuxMessageMembers() DemuxMessage {
+	return DemuxMessage{
+		msgType:   demuxMessageMembers,
+		membersCh: make(chan []*DemuxReader, 1),
 	}
 }
 
@@ -38,35 +51,108 @@ func newDemuxMessageRemove(reader *DemuxReader) DemuxMessage {
 	}
 }
 
+func newDemuxMessageFinished(reader *DemuxReader) DemuxMessage {
+	return DemuxMessage{
+		msgType: demuxMessageFinished,
+		reader:  reader,
+	}
+}
+
+// Sent when a reader has finished on its own, see WithEvents().
+type DemuxEvent struct {
+	Reader *DemuxReader
+	Err    error // Why reading stopped, nil after EOF
+}
+
+// An item of a Demux with tagging, see WithTagging().
+type Tagged struct {
+	Source string      // Name of the DemuxReader
+	Seq    int64       // Sequence number in the input ringbuf
+	Item   interface{} // The item itself
+}
+
 type DemuxReader struct {
+	counters memberCounters
 	reader   *ringbuf.Reader
+	name     string
+	weight   int
 	cancelCh chan bool
+	done     chan struct{} // Closed when Run() returns
 	onCancel func()
+	demux    *Demux // Told when reading stops, if set
 }
 
 func NewDemuxReader(reader *ringbuf.Reader) *DemuxReader {
 	return &DemuxReader{
 		reader:   reader,
+		weight:   1,
 		cancelCh: make(chan bool),
+		done:     make(chan struct{}),
+	}
+}
+
+// Returns ringbuf.ErrClosed if the reader has finished already.
+func (dr *DemuxReader) Cancel() error {
+	select {
+	case dr.cancelCh <- true:
+		return nil
+	case <-dr.done:
+		return ringbuf.ErrClosed
 	}
 }
 
-func (dr *DemuxReader) Cancel() {
-	dr.cancelCh <- true
+// The reason reading has stopped, if not because of EOF or Cancel().
+func (dr *DemuxReader) Err() error {
+	return dr.reader.Err()
 }
 
 func (dr *DemuxReader) SetOnCancel(f func()) {
 	dr.onCancel = f
 }
 
+// Set the Source of tagged items. Must be called before reading starts.
+func (dr *DemuxReader) SetName(name string) {
+	dr.name = name
+}
+
+func (dr *DemuxReader) Name() string {
+	return dr.name
+}
+
+// Set the share of the output ringbuf of this reader, relative to the
+// weight of the other readers, see WithFairness(). The default is one.
+// Must be called before reading starts.
+func (dr *DemuxReader) SetWeight(weight int) {
+	dr.weight = weight
+}
+
+func (dr *DemuxReader) Weight() int {
+	return dr.weight
+}
+
 func (dr *DemuxReader) Run(ring *ringbuf.Ringbuf) {
-	readCh := dr.reader.ReadCh()
+	dr.run(dr.reader.ReadItemCh(), ringOutput{ring}, false)
+}
+
+// Start reading right away, copy to out in the background.
+func (dr *DemuxReader) start(out output, tagged bool) {
+	go dr.run(dr.reader.ReadItemCh(), out, tagged)
+}
+
+func (dr *DemuxReader) run(itemCh <-chan ringbuf.Item, out output, tagged bool) {
 	readOnly := false
 
 	defer func() {
+		out.leave()
+		close(dr.done)
+
 		if dr.onCancel != nil {
 			dr.onCancel()
 		}
+
+		if dr.demux != nil {
+			dr.demux.send(newDemuxMessageFinished(dr))
+		}
 	}()
 
 	for {
@@ -74,48 +160,167 @@ func (dr *DemuxReader) Run(ring *ringbuf.Ringbuf) {
 		case <-dr.cancelCh:
 			dr.reader.Cancel()
 			readOnly = true
-		case data := <-readCh:
-			if data == nil {
+		case item, ok := <-itemCh:
+			if !ok {
 				return
 			}
 
-			if !readOnly {
-				ring.Write(data)
+			if readOnly {
+				continue
+			}
+
+			var data interface{} = item.Data
+			if tagged {
+				data = Tagged{Source: dr.name, Seq: item.Seq, Item: item.Data}
+			}
+
+			err := out.write(data, dr.cancelCh)
+			if err == errCanceled {
+				dr.reader.Cancel()
+				readOnly = true
+				continue
+			}
+
+			// Merged items are counted once written, see merger.flush().
+			if _, merged := out.(*mergeInput); !merged || err != nil {
+				dr.counters.record(err)
+			}
+
+			// Nowhere to write to, stop reading.
+			if err != nil {
+				dr.reader.Cancel()
+				readOnly = true
 			}
 		}
 	}
 }
 
+// Size of the output ringbuf, unless set with WithCapacity().
+const DefaultDemuxCapacity = 1024
+
 type Demux struct {
-	messageCh chan DemuxMessage
-	dataCh    chan interface{}
-	readers   []*DemuxReader
-	ring      *ringbuf.Ringbuf
+	messageCh  chan DemuxMessage
+	dataCh     chan interface{}
+	done       chan struct{} // Closed when Run() returns
+	readers    []*DemuxReader
+	ring       *ringbuf.Ringbuf
+	capacity   int64
+	external   bool // The output ringbuf is run by somebody else
+	tagged     bool // Write Tagged items
+	readerOpts []ringbuf.ReaderOption
+	less       func(a, b interface{}) bool // Merge in order, see WithOrder()
+	fair       bool                        // Merge by weight, see WithFairness()
+	maxWait    time.Duration
+	window     int
+	merge      *merger
+	eventCh    chan<- DemuxEvent // See WithEvents()
+	eof        bool              // See WithEOF()
+}
+
+// Panics if the options are not valid, see CreateDemux().
+func NewDemux(opts ...DemuxOption) *Demux {
+	d, err := CreateDemux(opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
 }
 
-func NewDemux() *Demux {
-	return &Demux{
+// Like NewDemux(), but returns an error wrapping ringbuf.ErrInvalidOptions
+// instead of panicking.
+func CreateDemux(opts ...DemuxOption) (*Demux, error) {
+	d := &Demux{
 		messageCh: make(chan DemuxMessage),
 		dataCh:    make(chan interface{}),
-		ring:      ringbuf.NewRingbuf(1024),
+		done:      make(chan struct{}),
 		readers:   make([]*DemuxReader, 0),
+		window:    DefaultMergeWindow,
+		capacity:  DefaultDemuxCapacity,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	// Shares are only known if nobody else writes to the output.
+	if d.fair && d.external {
+		return nil, fmt.Errorf("%w: WithFairness() needs an output ringbuf of its own", ringbuf.ErrInvalidOptions)
 	}
+
+	// Others might still write to an external output.
+	if d.eof && d.external {
+		return nil, fmt.Errorf("%w: WithEOF() needs an output ringbuf of its own", ringbuf.ErrInvalidOptions)
+	}
+
+	if d.ring == nil {
+		ring, err := ringbuf.CreateRingbuf(d.capacity)
+		if err != nil {
+			return nil, err
+		}
+
+		d.ring = ring
+	}
+
+	if d.less != nil || d.fair {
+		d.merge = newMerger(d)
+	}
+
+	return d, nil
 }
 
 func (d *Demux) String() string {
 	return fmt.Sprintf("Demux@%p", d)
 }
 
-func (d *Demux) Cancel() {
-	d.messageCh <- newDemuxMessageCancel()
+func (d *Demux) send(msg DemuxMessage) error {
+	select {
+	case d.messageCh <- msg:
+		return nil
+	case <-d.done:
+		return ringbuf.ErrClosed
+	}
+}
+
+// Returns ringbuf.ErrClosed if the demux is not running any more.
+func (d *Demux) Cancel() error {
+	return d.send(newDemuxMessageCancel())
 }
 
-func (d *Demux) Add(reader *DemuxReader) {
-	d.messageCh <- newDemuxMessageAdd(reader)
+// Returns when the reader is reading. Fails if the reader cannot join
+// the merge of the readers. A reader added already is reported to the
+// error channel of Run().
+func (d *Demux) Add(reader *DemuxReader) error {
+	msg := newDemuxMessageAdd(reader)
+	if err := d.send(msg); err != nil {
+		return err
+	}
+
+	return <-msg.addedCh
+}
+
+// Read from ring with the options given by WithReaderOptions(), then opts.
+// Returns the new reader, to Remove() it later.
+func (d *Demux) AddRing(ring *ringbuf.Ringbuf, opts ...ringbuf.ReaderOption) (*DemuxReader, error) {
+	return d.AddNamedRing("", ring, opts...)
+}
+
+// Like AddRing(), naming the reader for tagging.
+func (d *Demux) AddNamedRing(name string, ring *ringbuf.Ringbuf, opts ...ringbuf.ReaderOption) (*DemuxReader, error) {
+	opts = append(append([]ringbuf.ReaderOption(nil), d.readerOpts...), opts...)
+	reader := NewDemuxReader(ringbuf.NewReader(ring, opts...))
+	reader.SetName(name)
+
+	if err := d.Add(reader); err != nil {
+		return nil, err
+	}
+
+	return reader, nil
 }
 
-func (d *Demux) Remove(reader *DemuxReader) {
-    d.messageCh <- newDemuxMessageRemove(reader)
+// Errors unregistering the reader are sent to the error channel of Run().
+func (d *Demux) Remove(reader *DemuxReader) error {
+	return d.send(newDemuxMessageRemove(reader))
 }
 
 func (d *Demux) findReader(rr *DemuxReader) int {
@@ -133,31 +338,90 @@ func (d *Demux) handleMessage(errorCh chan<- error, msg DemuxMessage) bool {
 	case demuxMessageCancel:
 		return false
 	case demuxMessageAdd:
-		if d.findReader(msg.reader) < 0 {
-			go msg.reader.Run(d.ring)
+		if d.findReader(msg.reader) >= 0 {
+			msg.addedCh <- nil
+			errorCh <- fmt.Errorf("%s: %w", d, &ErrAlreadyRegistered{Reader: msg.reader})
+			break
+		}
+
+		out, err := d.output(msg.reader)
+		if err == nil {
+			msg.reader.demux = d
+			msg.reader.start(out, d.tagged)
 
 			d.readers = append(d.readers, msg.reader)
-		} else {
-			errorCh <- fmt.Errorf("%s: Attempt to insert reader %p that been inserted already", d, msg.reader)
 		}
+
+		msg.addedCh <- err
 	case demuxMessageRemove:
 		if i := d.findReader(msg.reader); i >= 0 {
 			d.readers[i].Cancel()
-            d.readers[i], d.readers[len(d.readers)-1], d.readers = d.readers[len(d.readers)-1], nil, d.readers[:len(d.readers)-1]
-        } else {
-			errorCh <- fmt.Errorf("%s: Attempt to delete a unregistered reader %p", d, msg.reader)
+			// Keep the order, see Readers().
+			d.readers = append(d.readers[:i], d.readers[i+1:]...)
+		} else {
+			errorCh <- fmt.Errorf("%s: reader %p: %w", d, msg.reader, ErrNotRegistered)
 		}
+	case demuxMessageMembers:
+		msg.membersCh <- append([]*DemuxReader(nil), d.readers...)
+	case demuxMessageFinished:
+		d.finished(msg.reader)
 	}
 
 	return true
 }
 
+// Unsafe. Must be called by the Demux main loop. Readers that were
+// removed or canceled with the Demux are not reported.
+func (d *Demux) finished(reader *DemuxReader) {
+	i := d.findReader(reader)
+	if i < 0 {
+		return
+	}
+
+	d.readers = append(d.readers[:i], d.readers[i+1:]...)
+
+	if d.eventCh != nil {
+		d.eventCh <- DemuxEvent{Reader: reader, Err: reader.Err()}
+	}
+
+	if d.eof && len(d.readers) == 0 {
+		if d.merge != nil {
+			// Items of the readers might not be written yet.
+			d.merge.eof()
+		} else {
+			d.ring.EOF()
+		}
+	}
+}
+
+// Unsafe. Must be called by the Demux main loop.
+func (d *Demux) output(dr *DemuxReader) (output, error) {
+	if d.merge != nil {
+		return d.merge.join(dr)
+	}
+
+	return ringOutput{d.ring}, nil
+}
+
 func (d *Demux) Reader() *ringbuf.Reader {
 	return ringbuf.NewReader(d.ring)
 }
 
+// The ringbuf all readers write to.
+func (d *Demux) Ringbuf() *ringbuf.Ringbuf {
+	return d.ring
+}
+
+// An external output ringbuf must be running already and is not
+// canceled when the Demux stops.
 func (d *Demux) Run(errorCh chan<- error) {
-	go d.ring.Run()
+	if !d.external {
+		go d.ring.Run()
+	}
+	if d.merge != nil {
+		go d.merge.run()
+	}
+	defer close(d.done)
 
 	for msg := range d.messageCh {
 		if !d.handleMessage(errorCh, msg) {
@@ -166,7 +430,13 @@ func (d *Demux) Run(errorCh chan<- error) {
 				d.readers[r].Cancel()
 			}
 
-			d.ring.Cancel()
+			if d.merge != nil {
+				d.merge.stop()
+			}
+
+			if !d.external {
+				d.ring.Cancel()
+			}
 			break
 		}
 	}
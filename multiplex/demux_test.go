@@ -1,8 +1,11 @@
 package multiplex
 
 import (
+	"errors"
 	"fmt"
 	"github.com/dullgiulio/ringbuf"
+	"io"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -196,3 +199,234 @@ func TestDemuxReadFromMany(t *testing.T) {
 	<-finishCh
 	wg.Wait()
 }
+
+func TestDemuxIntrospection(t *testing.T) {
+	rings := make([]*ringbuf.Ringbuf, 2)
+	readers := make([]*DemuxReader, 2)
+
+	demux := NewDemux()
+	go demux.Run(make(chan error))
+
+	for i := range rings {
+		rings[i] = ringbuf.NewRingbuf(10)
+		go rings[i].Run()
+
+		readers[i] = NewDemuxReader(ringbuf.NewReader(rings[i]))
+		demux.Add(readers[i])
+	}
+
+	rings[1].Write("test0")
+
+	for {
+		stats, err := demux.Stats()
+		if err != nil {
+			t.Fatal(fmt.Sprintf("Unexpected error: %v", err))
+		}
+
+		if stats[readers[1]].Forwarded == 1 {
+			if stats[readers[0]].Forwarded != 0 || !stats[readers[0]].LastActive.IsZero() {
+				t.Error(fmt.Sprintf("Unexpected stats %+v", stats[readers[0]]))
+			}
+			break
+		}
+	}
+
+	demux.Remove(readers[0])
+
+	if found, err := demux.Readers(); err != nil || len(found) != 1 || found[0] != readers[1] {
+		t.Error(fmt.Sprintf("Expected only the second reader, got %v %v", found, err))
+	}
+
+	if n, err := demux.Len(); err != nil || n != 1 {
+		t.Error(fmt.Sprintf("Expected 1 reader, got %d %v", n, err))
+	}
+
+	demux.Cancel()
+
+	for _, ring := range rings {
+		ring.Cancel()
+	}
+}
+
+func TestDemuxOptions(t *testing.T) {
+	output := ringbuf.NewRingbuf(5)
+	go output.Run()
+
+	input := ringbuf.NewRingbuf(10)
+	go input.Run()
+
+	input.Write("skip0")
+	input.Write("test0")
+
+	demux := NewDemux(WithOutput(output), WithReaderOptions(ringbuf.WithFilter(func(data interface{}) bool {
+		return !strings.HasPrefix(data.(string), "skip")
+	})))
+	go demux.Run(make(chan error))
+
+	if demux.Ringbuf() != output {
+		t.Error("Expected the external output ring")
+	}
+
+	reader, err := demux.AddRing(input, ringbuf.WithStartSeq(1))
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Unexpected error: %v", err))
+	}
+
+	input.Write("skip1")
+	input.Write("test1")
+
+	readCh := demux.Reader().ReadCh()
+
+	for i := 0; i < 2; i++ {
+		if data := <-readCh; data != fmt.Sprintf("test%d", i) {
+			t.Error(fmt.Sprintf("Expected 'test%d', got '%v'", i, data))
+		}
+	}
+
+	demux.Remove(reader)
+	demux.Cancel()
+	<-demux.done
+
+	// The external output is still running.
+	if err := output.Write("test2"); err != nil {
+		t.Error(fmt.Sprintf("Unexpected error: %v", err))
+	}
+
+	if data := <-readCh; data != "test2" {
+		t.Error(fmt.Sprintf("Expected 'test2', got '%v'", data))
+	}
+
+	output.Cancel()
+	input.Cancel()
+}
+
+func TestDemuxTagging(t *testing.T) {
+	rings := make([]*ringbuf.Ringbuf, 2)
+
+	demux := NewDemux(WithTagging())
+	go demux.Run(make(chan error))
+
+	for i := range rings {
+		rings[i] = ringbuf.NewRingbuf(10)
+		go rings[i].Run()
+
+		if _, err := demux.AddNamedRing(fmt.Sprintf("child%d", i), rings[i]); err != nil {
+			t.Fatal(fmt.Sprintf("Unexpected error: %v", err))
+		}
+	}
+
+	rings[0].Write("test0")
+	rings[1].Write("test1")
+	rings[1].Write("test2")
+
+	found := make(map[string]bool)
+	readCh := demux.Reader().ReadCh()
+
+	for i := 0; i < 3; i++ {
+		item := (<-readCh).(Tagged)
+		found[fmt.Sprintf("%s:%d:%s", item.Source, item.Seq, item.Item)] = true
+	}
+
+	for _, tag := range []string{"child0:0:test0", "child1:0:test1", "child1:1:test2"} {
+		if !found[tag] {
+			t.Error(fmt.Sprintf("Expected to find '%s' in %v", tag, found))
+		}
+	}
+
+	demux.Cancel()
+
+	for _, ring := range rings {
+		ring.Cancel()
+	}
+}
+
+func TestDemuxLifecycle(t *testing.T) {
+	less := func(a, b interface{}) bool {
+		return a.(string) < b.(string)
+	}
+
+	for _, opt := range []DemuxOption{WithTagging(), WithOrder(less, 0)} {
+		eventCh := make(chan DemuxEvent)
+
+		demux := NewDemux(opt, WithEvents(eventCh), WithEOF())
+		go demux.Run(make(chan error))
+
+		readCh := demux.Reader().ReadCh()
+		rings := runRings(2)
+
+		for _, ring := range rings {
+			if _, err := demux.AddRing(ring); err != nil {
+				t.Fatal(fmt.Sprintf("Unexpected error: %v", err))
+			}
+		}
+
+		for i, ring := range rings {
+			ring.Write(fmt.Sprintf("test%d", i))
+			ring.EOF()
+		}
+
+		for i := range rings {
+			if event := <-eventCh; event.Err != nil {
+				t.Error(fmt.Sprintf("Unexpected error from reader %d: %v", i, event.Err))
+			}
+		}
+
+		if n, _ := demux.Len(); n != 0 {
+			t.Error(fmt.Sprintf("Expected finished readers to be removed, got %d", n))
+		}
+
+		// The output ends after all items.
+		items := 0
+		for range readCh {
+			items++
+		}
+
+		if items != len(rings) {
+			t.Error(fmt.Sprintf("Expected %d items, got %d", len(rings), items))
+		}
+
+		demux.Cancel()
+	}
+}
+
+func TestDemuxBytesEOF(t *testing.T) {
+	demux := NewDemux(WithEOF())
+	go demux.Run(make(chan error))
+	defer demux.Cancel()
+
+	reader := ringbuf.NewReaderBytes(ringbuf.NewBytes(demux.Ringbuf()))
+	rings := runRings(1)
+
+	if _, err := demux.AddRing(rings[0]); err != nil {
+		t.Fatal(fmt.Sprintf("Unexpected error: %v", err))
+	}
+
+	for _, s := range []string{"some ", "data"} {
+		rings[0].Write([]byte(s))
+	}
+	rings[0].EOF()
+
+	// Ends cleanly once the input has finished.
+	data, err := io.ReadAll(reader)
+	if err != nil || string(data) != "some data" {
+		t.Error(fmt.Sprintf("Expected 'some data', got '%s' %v", data, err))
+	}
+}
+
+func TestDemuxEOFOutput(t *testing.T) {
+	if _, err := CreateDemux(WithOutput(ringbuf.NewRingbuf(10)), WithEOF()); !errors.Is(err, ringbuf.ErrInvalidOptions) {
+		t.Error(fmt.Sprintf("Expected ErrInvalidOptions, got %v", err))
+	}
+}
+
+func TestDemuxInvalidCapacity(t *testing.T) {
+	if _, err := CreateDemux(WithCapacity(0)); !errors.Is(err, ringbuf.ErrInvalidOptions) {
+		t.Error(fmt.Sprintf("Expected ErrInvalidOptions, got %v", err))
+	}
+
+	// The output given last wins, no ringbuf is created for the capacity.
+	out := ringbuf.NewRingbuf(10)
+	if demux, err := CreateDemux(WithCapacity(0), WithOutput(out)); err != nil || demux.Ringbuf() != out {
+		t.Error(fmt.Sprintf("Expected the given output, got %v", err))
+	}
+}
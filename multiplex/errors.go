@@ -0,0 +1,29 @@
+package multiplex
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dullgiulio/ringbuf"
+)
+
+var (
+	// Returned when removing a ringbuf or reader that was never added.
+	ErrNotRegistered = errors.New("multiplex: not registered")
+	// A ringbuf was detached because it could not keep up.
+	ErrQueueFull = errors.New("multiplex: queue full")
+)
+
+// Returned when adding a ringbuf or reader a second time. Only one
+// of Ring and Reader is set.
+type ErrAlreadyRegistered struct {
+	Ring   *ringbuf.Ringbuf
+	Reader *DemuxReader
+}
+
+func (e *ErrAlreadyRegistered) Error() string {
+	if e.Reader != nil {
+		return fmt.Sprintf("multiplex: reader %p is already registered", e.Reader)
+	}
+
+	return fmt.Sprintf("multiplex: ringbuf %p is already registered", e.Ring)
+}
@@ -0,0 +1,143 @@
+package multiplex
+
+import (
+	"github.com/dullgiulio/ringbuf"
+	"sync/atomic"
+	"time"
+)
+
+// How long to wait before the output ringbuf is looked at again while
+// readers are throttled, as nothing tells when its items are read.
+const throttleRetry = 10 * time.Millisecond
+
+// Looks at the output ringbuf for the merger, which must never wait for
+// it. The ringbuf is only looked at while a reader is throttled, and
+// the merger is told only when something changed.
+func (m *merger) watch() {
+	var last ringbuf.RingbufStats
+
+	for {
+		select {
+		case <-m.wantCh:
+		case <-m.done:
+			return
+		}
+
+		for {
+			stats, err := m.ring.Stats()
+			if err != nil {
+				return
+			}
+
+			if stats != last {
+				last = stats
+
+				select {
+				case m.statsCh <- stats:
+				case <-m.done:
+					return
+				}
+
+				break
+			}
+
+			select {
+			case <-time.After(throttleRetry):
+			case <-m.done:
+				return
+			}
+		}
+	}
+}
+
+// Unsafe. Must be called by the merger main loop. Ask for the output
+// ringbuf to be looked at, unless that is being done already.
+func (m *merger) want() {
+	select {
+	case m.wantCh <- struct{}{}:
+	default:
+	}
+}
+
+// Unsafe. Must be called by the merger main loop. Count for each input
+// its items in the ringbuf that the slowest reader has not read yet.
+// Items written since stats were taken are all unread.
+func (m *merger) countUnread(stats ringbuf.RingbufStats) {
+	for _, in := range m.inputs {
+		in.unread = 0
+	}
+
+	unread := stats.Lag + m.written - stats.Written
+	if unread > m.written {
+		unread = m.written
+	}
+
+	if size := int64(len(m.sources)); unread > size {
+		unread = size
+	}
+
+	for seq := m.written - unread; seq < m.written; seq++ {
+		if in := m.sources[seq%int64(len(m.sources))]; in != nil {
+			in.unread++
+		}
+	}
+}
+
+// Unsafe. Must be called by the merger main loop.
+func (m *merger) wrote(in *mergeInput) {
+	if m.fair {
+		m.sources[m.written%int64(len(m.sources))] = in
+		in.unread++
+	}
+
+	m.written++
+}
+
+// Unsafe. Must be called by the merger main loop. Items of in that
+// can be unread in the ringbuf, never less than one.
+func (m *merger) share(in *mergeInput) int {
+	total := 0
+	for _, other := range m.inputs {
+		total += other.weight
+	}
+
+	if share := len(m.sources) * in.weight / total; share > 1 {
+		return share
+	}
+
+	return 1
+}
+
+// Unsafe. Must be called by the merger main loop. Returns true if the
+// next item of in must wait for items of in to be read. The merger
+// is told once the ringbuf has changed.
+func (m *merger) throttle(in *mergeInput) bool {
+	if !m.fair || in.unread < m.share(in) {
+		return false
+	}
+
+	if !in.items[0].throttled {
+		in.items[0].throttled = true
+		atomic.AddInt64(&in.counters.throttled, 1)
+	}
+
+	m.want()
+
+	return true
+}
+
+// Unsafe. Must be called by the merger main loop. Returns the input
+// to write the next item of, taking turns.
+func (m *merger) nextFair() (*mergeInput, <-chan time.Time) {
+	for i := range m.inputs {
+		in := m.inputs[(m.next+i)%len(m.inputs)]
+		if len(in.items) == 0 || m.throttle(in) {
+			continue
+		}
+
+		m.next = (m.next + i + 1) % len(m.inputs)
+		return in, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,364 @@
+package multiplex
+
+import (
+	"errors"
+	"github.com/dullgiulio/ringbuf"
+	"time"
+)
+
+// Items held for each reader in a merge, unless set with WithWindow().
+const DefaultMergeWindow = 16
+
+// Items with a timestamp, see ByTimestamp().
+type Timestamped interface {
+	Timestamp() time.Time
+}
+
+// Order items by their timestamp, for WithOrder(). Items that are not
+// Timestamped come first.
+func ByTimestamp(a, b interface{}) bool {
+	return timestamp(a).Before(timestamp(b))
+}
+
+func timestamp(data interface{}) time.Time {
+	if ts, ok := data.(Timestamped); ok {
+		return ts.Timestamp()
+	}
+
+	return time.Time{}
+}
+
+// Returned by output.write() when the reader was canceled while waiting.
+var errCanceled = errors.New("multiplex: canceled")
+
+// Where a DemuxReader writes its items.
+type output interface {
+	// Can wait for room until a value is received from cancelCh.
+	write(data interface{}, cancelCh <-chan bool) error
+	// Called once when the reader stops reading.
+	leave()
+}
+
+// Write straight to a ringbuf, in no particular order.
+type ringOutput struct {
+	ring *ringbuf.Ringbuf
+}
+
+func (o ringOutput) write(data interface{}, cancelCh <-chan bool) error {
+	return o.ring.Write(data)
+}
+
+func (o ringOutput) leave() {}
+
+type mergedItem struct {
+	data      interface{}
+	arrived   time.Time
+	throttled bool // Already counted as throttled
+}
+
+// A reader taking part in a merge.
+type mergeInput struct {
+	merger   *merger
+	counters *memberCounters
+	weight   int
+	slotsCh  chan struct{} // Holds a token per item in the window
+	// Only used by the merger main loop.
+	items  []mergedItem // In order
+	left   bool
+	unread int // Items in the ringbuf not read yet, see countUnread()
+}
+
+func (in *mergeInput) write(data interface{}, cancelCh <-chan bool) error {
+	select {
+	case in.slotsCh <- struct{}{}:
+	case <-cancelCh:
+		return errCanceled
+	case <-in.merger.done:
+		return ringbuf.ErrClosed
+	}
+
+	return in.merger.send(mergeMessage{input: in, data: data})
+}
+
+func (in *mergeInput) leave() {
+	in.merger.send(mergeMessage{input: in, left: true})
+}
+
+// Only one of data, left, joined or eof is set.
+type mergeMessage struct {
+	input  *mergeInput
+	data   interface{}
+	left   bool
+	joined bool
+	eof    bool
+}
+
+// Writes the items of all readers to a ringbuf in the order given by less,
+// if set. An item is written once every reader has an item to compare it
+// with, or after it has waited for maxWait. With fair set, readers only
+// write their share of the ringbuf, see WithFairness().
+type merger struct {
+	ring    *ringbuf.Ringbuf
+	less    func(a, b interface{}) bool
+	fair    bool
+	window  int
+	maxWait time.Duration
+	msgCh   chan mergeMessage
+	stopCh  chan struct{}
+	done    chan struct{} // Closed when run() returns
+	// See want() and watch().
+	wantCh  chan struct{}
+	statsCh chan ringbuf.RingbufStats
+	inputs  []*mergeInput
+	next    int           // Next input to look at without less
+	sources []*mergeInput // Who wrote the last items, by position in the ringbuf
+	written int64         // Items written to the ringbuf
+	eofDue  bool          // EOF() once all items are written
+}
+
+func newMerger(d *Demux) *merger {
+	m := &merger{
+		ring:    d.ring,
+		less:    d.less,
+		fair:    d.fair,
+		window:  d.window,
+		maxWait: d.maxWait,
+		msgCh:   make(chan mergeMessage),
+		stopCh:  make(chan struct{}),
+		done:    make(chan struct{}),
+		wantCh:  make(chan struct{}, 1),
+		statsCh: make(chan ringbuf.RingbufStats),
+	}
+
+	if m.fair {
+		m.sources = make([]*mergeInput, d.ring.Size())
+	}
+
+	return m
+}
+
+func (m *merger) send(msg mergeMessage) error {
+	select {
+	case m.msgCh <- msg:
+		return nil
+	case <-m.done:
+		return ringbuf.ErrClosed
+	}
+}
+
+// Returns the output of a new reader. The reader is waited for
+// from now on, even before it writes anything.
+func (m *merger) join(dr *DemuxReader) (output, error) {
+	in := &mergeInput{
+		merger:   m,
+		counters: &dr.counters,
+		weight:   dr.weight,
+		slotsCh:  make(chan struct{}, m.window),
+	}
+
+	if in.weight < 1 {
+		in.weight = 1
+	}
+
+	if err := m.send(mergeMessage{input: in, joined: true}); err != nil {
+		return nil, err
+	}
+
+	return in, nil
+}
+
+// Send EOF() to the ringbuf once all items have been written.
+func (m *merger) eof() {
+	m.send(mergeMessage{eof: true})
+}
+
+// Discards the items not yet written.
+func (m *merger) stop() {
+	close(m.stopCh)
+	<-m.done
+}
+
+// Compare the items themselves, not their tags.
+func (m *merger) before(a, b interface{}) bool {
+	if tagged, ok := a.(Tagged); ok {
+		a = tagged.Item
+	}
+
+	if tagged, ok := b.(Tagged); ok {
+		b = tagged.Item
+	}
+
+	return m.less(a, b)
+}
+
+// Unsafe. Must be called by the merger main loop. Returns the input
+// with the smallest item, whether all inputs have an item, and when
+// the oldest item has been waiting since.
+func (m *merger) head() (*mergeInput, bool, time.Time) {
+	var (
+		min    *mergeInput
+		oldest time.Time
+	)
+
+	complete := true
+
+	for _, in := range m.inputs {
+		if len(in.items) == 0 {
+			// A reader that left has nothing more to compare with.
+			complete = complete && in.left
+			continue
+		}
+
+		if min == nil || m.before(in.items[0].data, min.items[0].data) {
+			min = in
+		}
+
+		for _, item := range in.items {
+			if oldest.IsZero() || item.arrived.Before(oldest) {
+				oldest = item.arrived
+			}
+		}
+	}
+
+	return min, complete, oldest
+}
+
+// Unsafe. Must be called by the merger main loop. Returns the input
+// to write the next item of, or when to try again, if ever.
+func (m *merger) nextOrdered() (*mergeInput, <-chan time.Time) {
+	min, complete, oldest := m.head()
+	if min == nil {
+		return nil, nil
+	}
+
+	if !complete {
+		if m.maxWait <= 0 {
+			return nil, nil
+		}
+
+		if wait := m.maxWait - time.Since(oldest); wait > 0 {
+			return nil, time.After(wait)
+		}
+	}
+
+	// Nothing else can be written before this item.
+	if m.throttle(min) {
+		return nil, nil
+	}
+
+	return min, nil
+}
+
+// Unsafe. Must be called by the merger main loop. Writes all items
+// that can be written, then returns when to try again, if ever.
+func (m *merger) flush() <-chan time.Time {
+	m.prune()
+
+	for {
+		next := m.nextFair
+		if m.less != nil {
+			next = m.nextOrdered
+		}
+
+		in, retryCh := next()
+		if in == nil {
+			if retryCh == nil && m.eofDue && m.drained() {
+				m.eofDue = false
+				m.ring.EOF()
+			}
+
+			return retryCh
+		}
+
+		item := in.items[0]
+		in.items[0], in.items = mergedItem{}, in.items[1:]
+		<-in.slotsCh
+
+		// Only fails when the ringbuf is closing, the item is lost then.
+		err := m.ring.Write(item.data)
+		in.counters.record(err)
+
+		if err == nil {
+			m.wrote(in)
+		}
+	}
+}
+
+// Unsafe. Must be called by the merger main loop.
+func (m *merger) drained() bool {
+	for _, in := range m.inputs {
+		if len(in.items) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Unsafe. Must be called by the merger main loop. Forget readers that
+// left and have nothing left to write.
+func (m *merger) prune() {
+	inputs := m.inputs[:0]
+
+	for _, in := range m.inputs {
+		if !in.left || len(in.items) > 0 {
+			inputs = append(inputs, in)
+		}
+	}
+
+	for i := len(inputs); i < len(m.inputs); i++ {
+		m.inputs[i] = nil
+	}
+
+	m.inputs = inputs
+}
+
+func (m *merger) handleMessage(msg mergeMessage) {
+	switch {
+	case msg.joined:
+		m.inputs = append(m.inputs, msg.input)
+	case msg.left:
+		msg.input.left = true
+	case msg.eof:
+		m.eofDue = true
+	default:
+		msg.input.insert(m, mergedItem{data: msg.data, arrived: time.Now()})
+	}
+}
+
+// Unsafe. Must be called by the merger main loop. Items that arrive
+// out of order are put in order within the window, if there is one.
+func (in *mergeInput) insert(m *merger, item mergedItem) {
+	i := len(in.items)
+	for m.less != nil && i > 0 && m.before(item.data, in.items[i-1].data) {
+		i--
+	}
+
+	in.items = append(in.items, mergedItem{})
+	copy(in.items[i+1:], in.items[i:])
+	in.items[i] = item
+}
+
+func (m *merger) run() {
+	defer close(m.done)
+
+	if m.fair {
+		go m.watch()
+	}
+
+	var retryCh <-chan time.Time
+
+	for {
+		select {
+		case msg := <-m.msgCh:
+			m.handleMessage(msg)
+		case stats := <-m.statsCh:
+			m.countUnread(stats)
+		case <-retryCh:
+		case <-m.stopCh:
+			return
+		}
+
+		retryCh = m.flush()
+	}
+}
@@ -0,0 +1,204 @@
+package multiplex
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dullgiulio/ringbuf"
+	"testing"
+	"time"
+)
+
+type event struct {
+	at   time.Time
+	name string
+}
+
+func (e event) Timestamp() time.Time {
+	return e.at
+}
+
+func TestDemuxOrder(t *testing.T) {
+	less := func(a, b interface{}) bool {
+		return a.(int) < b.(int)
+	}
+
+	demux := NewDemux(WithOrder(less, 0), WithWindow(2))
+	go demux.Run(make(chan error))
+	defer demux.Cancel()
+
+	readCh := demux.Reader().ReadCh()
+	rings := runRings(2)
+
+	for _, ring := range rings {
+		if _, err := demux.AddRing(ring); err != nil {
+			t.Fatal(fmt.Sprintf("Unexpected error: %v", err))
+		}
+	}
+
+	// One input is well ahead of the other.
+	go func() {
+		for i := 1; i < 8; i += 2 {
+			rings[0].Write(i)
+		}
+		rings[0].EOF()
+	}()
+
+	for i := 2; i <= 8; i += 2 {
+		rings[1].Write(i)
+	}
+	rings[1].EOF()
+
+	for i := 1; i <= 8; i++ {
+		if data := <-readCh; data != i {
+			t.Fatal(fmt.Sprintf("Expected %d, got %v", i, data))
+		}
+	}
+}
+
+func TestDemuxOrderMaxWait(t *testing.T) {
+	demux := NewDemux(WithOrder(ByTimestamp, 50*time.Millisecond), WithTagging())
+	go demux.Run(make(chan error))
+	defer demux.Cancel()
+
+	readCh := demux.Reader().ReadCh()
+	rings := runRings(2)
+
+	for i, ring := range rings {
+		if _, err := demux.AddNamedRing(fmt.Sprintf("child%d", i), ring); err != nil {
+			t.Fatal(fmt.Sprintf("Unexpected error: %v", err))
+		}
+	}
+
+	// Out of order, but within the window.
+	now := time.Now()
+	rings[0].Write(event{at: now.Add(2 * time.Second), name: "second"})
+	rings[0].Write(event{at: now.Add(time.Second), name: "first"})
+
+	// The other input is stalled, items are released after the maximum wait.
+	for _, name := range []string{"first", "second"} {
+		select {
+		case data := <-readCh:
+			if item := data.(Tagged); item.Source != "child0" || item.Item.(event).name != name {
+				t.Error(fmt.Sprintf("Expected %s from child0, got %v", name, item))
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Items of a stalled input were not released")
+		}
+	}
+
+	for _, ring := range rings {
+		ring.Cancel()
+	}
+}
+
+func TestDemuxFairness(t *testing.T) {
+	demux := NewDemux(WithCapacity(10), WithFairness())
+	go demux.Run(make(chan error))
+	defer demux.Cancel()
+
+	reader := demux.Reader()
+	readCh := reader.ReadCh()
+	rings := runRings(2)
+
+	chatty := NewDemuxReader(ringbuf.NewReader(rings[0]))
+	quiet := NewDemuxReader(ringbuf.NewReader(rings[1]))
+	quiet.SetWeight(2)
+
+	for _, dr := range []*DemuxReader{chatty, quiet} {
+		if err := demux.Add(dr); err != nil {
+			t.Fatal(fmt.Sprintf("Unexpected error: %v", err))
+		}
+	}
+
+	// The reader of the output is reading from here on.
+	rings[1].Write("quiet0")
+	if data := <-readCh; data != "quiet0" {
+		t.Fatal(fmt.Sprintf("Expected quiet0, got %v", data))
+	}
+
+	for i := 0; i < 30; i++ {
+		rings[0].Write(fmt.Sprintf("chatty%d", i))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats, _ := demux.Stats()
+		if stats[chatty].Throttled > 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the chatty reader to be throttled")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	rings[1].Write("quiet1")
+
+	found := make(map[interface{}]bool)
+	for i := 0; i < 31; i++ {
+		found[<-readCh] = true
+	}
+
+	if !found["quiet1"] || !found["chatty29"] {
+		t.Error(fmt.Sprintf("Expected all items, got %v", found))
+	}
+
+	if stats, _ := reader.Stats(); stats.Dropped != 0 {
+		t.Error(fmt.Sprintf("Expected no items to be dropped, got %d", stats.Dropped))
+	}
+
+	for _, ring := range rings {
+		ring.Cancel()
+	}
+}
+
+func TestDemuxOrderWriteErrors(t *testing.T) {
+	less := func(a, b interface{}) bool {
+		return a.(int) < b.(int)
+	}
+
+	// Nothing can be written to the output any more.
+	out := ringbuf.NewRingbuf(10)
+	go out.Run()
+	out.EOF()
+
+	demux := NewDemux(WithOutput(out), WithOrder(less, 0))
+	go demux.Run(make(chan error))
+	defer demux.Cancel()
+
+	rings := runRings(1)
+	dr, err := demux.AddRing(rings[0])
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Unexpected error: %v", err))
+	}
+
+	rings[0].Write(1)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats, _ := demux.Stats()
+		if stats[dr].Errors == 1 {
+			if stats[dr].Forwarded != 0 {
+				t.Error(fmt.Sprintf("Expected nothing forwarded, got %+v", stats[dr]))
+			}
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal(fmt.Sprintf("Expected a write error, got %+v", stats[dr]))
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	rings[0].Cancel()
+	out.Cancel()
+}
+
+func TestDemuxFairnessOutput(t *testing.T) {
+	if _, err := CreateDemux(WithOutput(ringbuf.NewRingbuf(10)), WithFairness()); !errors.Is(err, ringbuf.ErrInvalidOptions) {
+		t.Error(fmt.Sprintf("Expected ErrInvalidOptions, got %v", err))
+	}
+}
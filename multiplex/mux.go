@@ -3,19 +3,30 @@ package multiplex
 import (
 	"fmt"
 	"github.com/dullgiulio/ringbuf"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	muxMessageCancel = iota
 	muxMessageAdd
 	muxMessageRemove
+	muxMessageAddRoute
+	muxMessageClose
+	muxMessageMembers
 )
 
 type MuxMessageType int
 
 type MuxMessage struct {
-	msgType MuxMessageType
-	ring    *ringbuf.Ringbuf
+	msgType   MuxMessageType
+	ring      *ringbuf.Ringbuf
+	filter    func(data interface{}) bool
+	key       interface{}
+	opts      []TargetOption
+	mode      CloseMode
+	membersCh chan []*sink
 }
 
 func newMuxMessageCancel() MuxMessage {
@@ -24,10 +35,35 @@ func newMuxMessageCancel() MuxMessage {
 	}
 }
 
-func newMuxMessageAdd(ring *ringbuf.Ringbuf) MuxMessage {
+func newMuxMessageClose(mode CloseMode) MuxMessage {
+	return MuxMessage{
+		msgType: muxMessageClose,
+		mode:    mode,
+	}
+}
+
+func newMuxMessageMembers() MuxMessage {
+	return MuxMessage{
+		msgType:   muxMessageMembers,
+		membersCh: make(chan []*sink, 1),
+	}
+}
+
+func newMuxMessageAdd(ring *ringbuf.Ringbuf, filter func(data interface{}) bool, opts []TargetOption) MuxMessage {
 	return MuxMessage{
 		msgType: muxMessageAdd,
 		ring:    ring,
+		filter:  filter,
+		opts:    opts,
+	}
+}
+
+func newMuxMessageAddRoute(key interface{}, ring *ringbuf.Ringbuf, opts []TargetOption) MuxMessage {
+	return MuxMessage{
+		msgType: muxMessageAddRoute,
+		ring:    ring,
+		key:     key,
+		opts:    opts,
 	}
 }
 
@@ -38,44 +74,129 @@ func newMuxMessageRemove(ring *ringbuf.Ringbuf) MuxMessage {
 	}
 }
 
+// A ringbuf the Mux distributes items to.
+type target struct {
+	sink   *sink
+	filter func(data interface{}) bool // Only write items it accepts, if set
+	id     uint64                      // Unique in its Mux, for hashing
+}
+
+// Counters of a Mux.
+type MuxCounters struct {
+	Matched    int64 // Items written to at least one ringbuf
+	Defaulted  int64 // Items only written to the default route
+	Dropped    int64 // Items no ringbuf wanted
+	Overflowed int64 // Items not queued because a queue was full
+}
+
 type Mux struct {
 	messageCh chan MuxMessage
 	dataCh    chan interface{}
-	rings     []*ringbuf.Ringbuf
-	running   bool
+	done      chan struct{} // Closed when Run() returns
+	stopping  chan struct{} // Closed when Run() starts returning
+	abortCh   chan struct{} // Closed when Run() stops waiting for the sinks
+	rings     []*target
+	routes    map[interface{}][]*sink
+	sinks     map[*ringbuf.Ringbuf]*sink
+	sinksWg   sync.WaitGroup
+	key       ringbuf.KeyFunc
+	fallback  *ringbuf.Ringbuf
+	strategy  Strategy
+	closeMode CloseMode
+	drainWait time.Duration
+	next      int    // Next target for StrategyRoundRobin
+	lastID    uint64 // Last id given out to a target or sink
+	// Accessed atomically.
+	running    int32
+	matched    int64
+	defaulted  int64
+	dropped    int64
+	overflowed int64
 }
 
-func NewMux() *Mux {
-	return &Mux{
+func NewMux(opts ...MuxOption) *Mux {
+	m := &Mux{
 		messageCh: make(chan MuxMessage),
 		dataCh:    make(chan interface{}),
-		rings:     make([]*ringbuf.Ringbuf, 0),
+		done:      make(chan struct{}),
+		stopping:  make(chan struct{}),
+		abortCh:   make(chan struct{}),
+		rings:     make([]*target, 0),
+		routes:    make(map[interface{}][]*sink),
+		sinks:     make(map[*ringbuf.Ringbuf]*sink),
+		drainWait: DefaultDrainTimeout,
 	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
 }
 
 func (m *Mux) String() string {
 	return fmt.Sprintf("Mux@%p", m)
 }
 
-func (m *Mux) Write(data interface{}) {
-	m.dataCh <- data
+// Returns ringbuf.ErrClosed if the mux is not running any more.
+func (m *Mux) Write(data interface{}) error {
+	select {
+	case m.dataCh <- data:
+		return nil
+	case <-m.done:
+		return ringbuf.ErrClosed
+	}
 }
 
-func (m *Mux) Cancel() {
-	m.messageCh <- newMuxMessageCancel()
+func (m *Mux) send(msg MuxMessage) error {
+	select {
+	case m.messageCh <- msg:
+		return nil
+	case <-m.done:
+		return ringbuf.ErrClosed
+	}
 }
 
-func (m *Mux) Add(ring *ringbuf.Ringbuf) {
-	m.messageCh <- newMuxMessageAdd(ring)
+func (m *Mux) Cancel() error {
+	return m.send(newMuxMessageCancel())
 }
 
-func (m *Mux) Remove(ring *ringbuf.Ringbuf) {
-	m.messageCh <- newMuxMessageRemove(ring)
+// Errors registering the ringbuf are sent to the error channel of Run().
+// The options apply the first time a ringbuf is added, in any way.
+func (m *Mux) Add(ring *ringbuf.Ringbuf, opts ...TargetOption) error {
+	return m.send(newMuxMessageAdd(ring, nil, opts))
+}
+
+// Like Add(), but only items for which filter is true are written to ring.
+func (m *Mux) AddWithFilter(ring *ringbuf.Ringbuf, filter func(data interface{}) bool, opts ...TargetOption) error {
+	return m.send(newMuxMessageAdd(ring, filter, opts))
+}
+
+// Write items whose key is key to ring. The key of items is given by the
+// KeyFunc set with WithRouteKey(). A ringbuf can be the route of many keys.
+func (m *Mux) AddRoute(key interface{}, ring *ringbuf.Ringbuf, opts ...TargetOption) error {
+	return m.send(newMuxMessageAddRoute(key, ring, opts))
+}
+
+// Unregister ring, including all its routes. Errors unregistering the
+// ringbuf are sent to the error channel of Run().
+func (m *Mux) Remove(ring *ringbuf.Ringbuf) error {
+	return m.send(newMuxMessageRemove(ring))
+}
+
+// Returns how many items have been written so far, and where.
+func (m *Mux) Counters() MuxCounters {
+	return MuxCounters{
+		Matched:    atomic.LoadInt64(&m.matched),
+		Defaulted:  atomic.LoadInt64(&m.defaulted),
+		Dropped:    atomic.LoadInt64(&m.dropped),
+		Overflowed: atomic.LoadInt64(&m.overflowed),
+	}
 }
 
 func (m *Mux) findRing(rf *ringbuf.Ringbuf) int {
 	for r := range m.rings {
-		if m.rings[r] == rf {
+		if m.rings[r].sink.ring == rf {
 			return r
 		}
 	}
@@ -83,44 +204,141 @@ func (m *Mux) findRing(rf *ringbuf.Ringbuf) int {
 	return -1
 }
 
+func findRoute(sinks []*sink, rf *ringbuf.Ringbuf) int {
+	for s := range sinks {
+		if sinks[s].ring == rf {
+			return s
+		}
+	}
+
+	return -1
+}
+
+// Remove ring from all routes, returns false if it had none.
+func (m *Mux) removeRoutes(ring *ringbuf.Ringbuf) bool {
+	found := false
+
+	for key, sinks := range m.routes {
+		if i := findRoute(sinks, ring); i >= 0 {
+			found = true
+
+			sinks[i], sinks[len(sinks)-1], sinks = sinks[len(sinks)-1], nil, sinks[:len(sinks)-1]
+			if len(sinks) == 0 {
+				delete(m.routes, key)
+			} else {
+				m.routes[key] = sinks
+			}
+		}
+	}
+
+	return found
+}
+
+// Unregister ring everywhere. Returns false if it was not registered.
+func (m *Mux) remove(ring *ringbuf.Ringbuf) bool {
+	found := m.removeRoutes(ring)
+
+	if i := m.findRing(ring); i >= 0 {
+		m.rings[i], m.rings[len(m.rings)-1], m.rings = m.rings[len(m.rings)-1], nil, m.rings[:len(m.rings)-1]
+		found = true
+	}
+
+	// The default route keeps using its ringbuf.
+	if found && ring != m.fallback {
+		m.closeSink(ring)
+	}
+
+	return found
+}
+
 func (m *Mux) handleMessage(errorCh chan<- error, msg MuxMessage) bool {
 	switch msg.msgType {
 	case muxMessageCancel:
 		return false
+	case muxMessageClose:
+		m.closeMode = msg.mode
+		return false
 	case muxMessageAdd:
 		if m.findRing(msg.ring) < 0 {
-			m.rings = append(m.rings, msg.ring)
+			m.lastID++
+			m.rings = append(m.rings, &target{
+				sink:   m.sink(errorCh, msg.ring, msg.opts),
+				filter: msg.filter,
+				id:     m.lastID,
+			})
 		} else {
-			errorCh <- fmt.Errorf("%s: Attempt to insert ringbuf %p that has been inserted already", m, msg.ring)
+			errorCh <- fmt.Errorf("%s: %w", m, &ErrAlreadyRegistered{Ring: msg.ring})
 		}
-	case muxMessageRemove:
-		if i := m.findRing(msg.ring); i >= 0 {
-			m.rings[i], m.rings[len(m.rings)-1], m.rings = m.rings[len(m.rings)-1], nil, m.rings[:len(m.rings)-1]
+	case muxMessageAddRoute:
+		if findRoute(m.routes[msg.key], msg.ring) < 0 {
+			m.routes[msg.key] = append(m.routes[msg.key], m.sink(errorCh, msg.ring, msg.opts))
 		} else {
-			errorCh <- fmt.Errorf("%s: Attempt to delete a unregistered ring %p", m, msg.ring)
+			errorCh <- fmt.Errorf("%s: route %v: %w", m, msg.key, &ErrAlreadyRegistered{Ring: msg.ring})
+		}
+	case muxMessageRemove:
+		if !m.remove(msg.ring) {
+			errorCh <- fmt.Errorf("%s: ringbuf %p: %w", m, msg.ring, ErrNotRegistered)
 		}
+	case muxMessageMembers:
+		msg.membersCh <- m.sortedSinks()
 	}
 
 	return true
 }
 
 func (m *Mux) handleData(errorCh chan<- error, data interface{}) {
-	for r := range m.rings {
-		if m.rings[r] != nil {
-			m.rings[r].Write(data)
+	var full []*sink
+
+	queue := func(s *sink) {
+		if !m.enqueue(s, data) {
+			full = append(full, s)
+		}
+	}
+
+	matched := m.distribute(data, queue)
+
+	if m.key != nil {
+		for _, s := range m.routes[m.key(data)] {
+			queue(s)
+			matched = true
 		}
 	}
+
+	switch {
+	case matched:
+		atomic.AddInt64(&m.matched, 1)
+	case m.fallback != nil:
+		queue(m.sinks[m.fallback])
+		atomic.AddInt64(&m.defaulted, 1)
+	default:
+		atomic.AddInt64(&m.dropped, 1)
+	}
+
+	for _, s := range full {
+		m.detach(errorCh, s)
+	}
 }
 
-func (m *Mux) Run(errorCh chan<- error) {
-	m.running = true
+// Returns after the queued items have been written. The error tells
+// which ringbufs could not be closed, see Close().
+func (m *Mux) Run(errorCh chan<- error) error {
+	atomic.StoreInt32(&m.running, 1)
+	defer close(m.done)
+	defer atomic.StoreInt32(&m.running, 0)
+
+	if m.fallback != nil {
+		m.sink(errorCh, m.fallback, nil)
+	}
 
 	for {
 		select {
 		case msg := <-m.messageCh:
 			if !m.handleMessage(errorCh, msg) {
-				m.running = false
-				return
+				rings := m.registered()
+
+				m.stop()
+
+				return m.closeRings(rings)
 			}
 		case data := <-m.dataCh:
 			m.handleData(errorCh, data)
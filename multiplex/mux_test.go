@@ -1,9 +1,12 @@
 package multiplex
 
 import (
+	"errors"
 	"fmt"
 	"github.com/dullgiulio/ringbuf"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMuxCancel(t *testing.T) {
@@ -13,7 +16,7 @@ func TestMuxCancel(t *testing.T) {
         t.Error("Expected a string conversion")
     }
 
-	if mux.running == true {
+	if mux.Running() {
 		t.Error("Newly created mux is already marked as running.")
 	}
 
@@ -21,8 +24,9 @@ func TestMuxCancel(t *testing.T) {
 	go mux.Run(errorCh)
 
 	mux.Cancel()
+	<-mux.Done()
 
-	if mux.running == true {
+	if mux.Running() {
 		t.Error("Stopped mux still marked as running.")
 	}
 }
@@ -105,3 +109,387 @@ func TestMuxWritesToRings(t *testing.T) {
 
 	rings[1].Cancel()
 }
+
+func TestMuxErrors(t *testing.T) {
+	mux := NewMux()
+	errorCh := make(chan error)
+	done := make(chan bool)
+
+	ring := ringbuf.NewRingbuf(10)
+
+	go func() {
+		mux.Run(errorCh)
+		done <- true
+	}()
+
+	mux.Add(ring)
+	mux.Add(ring)
+
+	var registered *ErrAlreadyRegistered
+	if err := <-errorCh; !errors.As(err, &registered) || registered.Ring != ring {
+		t.Errorf("Expected ErrAlreadyRegistered for ring %p, got %v", ring, err)
+	}
+
+	mux.Remove(ring)
+	mux.Remove(ring)
+
+	if err := <-errorCh; !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("Expected ErrNotRegistered, got %v", err)
+	}
+
+	mux.Cancel()
+	<-done
+
+	if err := mux.Write("test0"); !errors.Is(err, ringbuf.ErrClosed) {
+		t.Errorf("Expected ErrClosed writing to a stopped mux, got %v", err)
+	}
+
+	if err := mux.Cancel(); !errors.Is(err, ringbuf.ErrClosed) {
+		t.Errorf("Expected ErrClosed canceling a stopped mux, got %v", err)
+	}
+}
+
+func TestMuxRoutes(t *testing.T) {
+	severity := func(data interface{}) interface{} {
+		return strings.SplitN(data.(string), ":", 2)[0]
+	}
+
+	errorRing := ringbuf.NewRingbuf(10)
+	warningRing := ringbuf.NewRingbuf(10)
+	long := ringbuf.NewRingbuf(10)
+	other := ringbuf.NewRingbuf(10)
+
+	rings := []*ringbuf.Ringbuf{errorRing, warningRing, long, other}
+	for _, ring := range rings {
+		go ring.Run()
+	}
+
+	mux := NewMux(WithRouteKey(severity), WithDefaultRoute(other))
+	errorCh := make(chan error)
+
+	stop := startMux(mux, errorCh)
+
+	mux.AddRoute("error", errorRing)
+	mux.AddRoute("warning", warningRing)
+	mux.AddWithFilter(long, func(data interface{}) bool {
+		return len(data.(string)) > 10
+	})
+
+	mux.Write("error:disk")
+	mux.Write("warning:cpu")
+	mux.Write("info:started")
+	mux.Write("debug:x")
+
+	mux.Remove(warningRing)
+	mux.Write("warning:mem")
+
+	stop()
+
+	expected := map[*ringbuf.Ringbuf]string{
+		errorRing:   "[error:disk]",
+		warningRing: "[warning:cpu]",
+		long:        "[warning:cpu info:started warning:mem]",
+		other:       "[debug:x]",
+	}
+
+	for ring, items := range expected {
+		var found []interface{}
+		for _, data := range ring.Retained() {
+			found = append(found, data)
+		}
+
+		if fmt.Sprint(found) != items {
+			t.Error(fmt.Sprintf("Expected %s, got %v", items, found))
+		}
+
+		ring.Cancel()
+	}
+
+	if c := mux.Counters(); c.Matched != 4 || c.Defaulted != 1 || c.Dropped != 0 {
+		t.Error(fmt.Sprintf("Unexpected counters %+v", c))
+	}
+}
+
+func TestMuxOverflow(t *testing.T) {
+	rings := runRings(1)
+	// Not running yet, writing to it blocks.
+	stalled := ringbuf.NewRingbuf(10)
+
+	mux := NewMux()
+	errorCh := make(chan error)
+	stop := startMux(mux, errorCh)
+
+	mux.Add(rings[0])
+	mux.Add(stalled, WithQueueSize(1), WithOverflow(OverflowDrop))
+
+	for i := 0; i < 5; i++ {
+		mux.Write(i)
+	}
+
+	go stalled.Run()
+	stop()
+
+	if items := fmt.Sprint(retained(rings[0])); items != "[0 1 2 3 4]" {
+		t.Error(fmt.Sprintf("Expected all items in the running ring, got %s", items))
+	}
+
+	// One item is being written, one is queued.
+	items := retained(stalled)
+	if c := mux.Counters(); len(items) > 2 || c.Overflowed != int64(5-len(items)) {
+		t.Error(fmt.Sprintf("Unexpected items %v with counters %+v", items, c))
+	}
+
+	rings[0].Cancel()
+	stalled.Cancel()
+}
+
+func TestMuxOverflowDetach(t *testing.T) {
+	rings := runRings(1)
+	stalled := ringbuf.NewRingbuf(10)
+
+	mux := NewMux()
+	errorCh := make(chan error)
+	stop := startMux(mux, errorCh)
+
+	mux.Add(rings[0])
+	mux.Add(stalled, WithQueueSize(1), WithOverflow(OverflowDetach))
+
+	written := make(chan bool)
+	go func() {
+		for i := 0; i < 5; i++ {
+			mux.Write(i)
+		}
+		written <- true
+	}()
+
+	if err := <-errorCh; !errors.Is(err, ErrQueueFull) {
+		t.Error(fmt.Sprintf("Expected ErrQueueFull, got %v", err))
+	}
+
+	// The detached ring is not registered any more.
+	mux.Remove(stalled)
+
+	if err := <-errorCh; !errors.Is(err, ErrNotRegistered) {
+		t.Error(fmt.Sprintf("Expected ErrNotRegistered, got %v", err))
+	}
+
+	<-written
+	go stalled.Run()
+	stop()
+
+	if items := fmt.Sprint(retained(rings[0])); items != "[0 1 2 3 4]" {
+		t.Error(fmt.Sprintf("Expected all items in the running ring, got %s", items))
+	}
+
+	rings[0].Cancel()
+	stalled.Cancel()
+}
+
+func TestMuxBurst(t *testing.T) {
+	ring := ringbuf.NewRingbuf(1000)
+	go ring.Run()
+
+	mux := NewMux()
+	stop := startMux(mux, make(chan error))
+
+	mux.Add(ring)
+
+	// Much more than the queue holds, nothing is dropped.
+	for i := 0; i < 8*DefaultQueueSize; i++ {
+		mux.Write(i)
+	}
+	stop()
+
+	if items := retained(ring); len(items) != 8*DefaultQueueSize || items[len(items)-1] != 8*DefaultQueueSize-1 {
+		t.Error(fmt.Sprintf("Expected all %d items, got %d", 8*DefaultQueueSize, len(items)))
+	}
+
+	if c := mux.Counters(); c.Overflowed != 0 {
+		t.Error(fmt.Sprintf("Unexpected counters %+v", c))
+	}
+
+	ring.Cancel()
+}
+
+func TestMuxNegativeQueueSize(t *testing.T) {
+	rings := runRings(1)
+
+	mux := NewMux()
+	stop := startMux(mux, make(chan error))
+
+	mux.Add(rings[0], WithQueueSize(-1))
+	mux.Write("test0")
+	stop()
+
+	if items := fmt.Sprint(retained(rings[0])); items != "[test0]" {
+		t.Error(fmt.Sprintf("Expected the item without a queue, got %s", items))
+	}
+
+	rings[0].Cancel()
+}
+
+func TestMuxDrainTimeout(t *testing.T) {
+	// Not running, writing to it blocks.
+	stalled := ringbuf.NewRingbuf(10)
+
+	mux := NewMux(WithDrainTimeout(10 * time.Millisecond))
+	done := make(chan bool)
+
+	go func() {
+		mux.Run(make(chan error))
+		done <- true
+	}()
+
+	mux.Add(stalled)
+
+	for i := 0; i < 3; i++ {
+		mux.Write(i)
+	}
+
+	mux.Cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Run() to return despite a stalled ringbuf")
+	}
+
+	// The item being written is written now, the others were dropped.
+	go stalled.Run()
+
+	deadline := time.Now().Add(time.Second)
+	for len(retained(stalled)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the pending write to finish")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if items := fmt.Sprint(retained(stalled)); items != "[0]" {
+		t.Error(fmt.Sprintf("Expected only the first item, got %s", items))
+	}
+
+	stalled.Cancel()
+}
+
+func TestMuxCloseEOF(t *testing.T) {
+	rings := runRings(2)
+
+	mux := NewMux()
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- mux.Run(make(chan error))
+	}()
+
+	mux.Add(rings[0])
+	mux.Add(rings[1])
+
+	for i := 0; i < 3; i++ {
+		mux.Write(i)
+	}
+
+	mux.Close(CloseEOF)
+
+	// Readers get EOF after reading everything.
+	for _, ring := range rings {
+		var items []interface{}
+		for data := range ringbuf.NewReader(ring).All() {
+			items = append(items, data)
+		}
+
+		if fmt.Sprint(items) != "[0 1 2]" {
+			t.Error(fmt.Sprintf("Expected [0 1 2], got %v", items))
+		}
+
+		ring.Cancel()
+	}
+
+	<-mux.Done()
+
+	if err := <-errCh; err != nil {
+		t.Error(fmt.Sprintf("Unexpected error: %v", err))
+	}
+}
+
+func TestMuxCloseCancel(t *testing.T) {
+	rings := runRings(2)
+
+	mux := NewMux()
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- mux.Run(make(chan error))
+	}()
+
+	mux.Add(rings[0])
+	mux.Add(rings[1])
+
+	// Stop one ring first, it can't be closed any more.
+	rings[1].Cancel()
+	for rings[1].Write("test0") == nil {
+	}
+
+	mux.Close(CloseCancel)
+
+	if err := <-errCh; !errors.Is(err, ringbuf.ErrClosed) {
+		t.Error(fmt.Sprintf("Expected ErrClosed, got %v", err))
+	}
+
+	if err := rings[0].Write("test1"); !errors.Is(err, ringbuf.ErrClosed) {
+		t.Error(fmt.Sprintf("Expected the ring to be canceled, got %v", err))
+	}
+}
+
+func TestMuxIntrospection(t *testing.T) {
+	rings := runRings(3)
+
+	mux := NewMux(WithRouteKey(func(data interface{}) interface{} {
+		return data
+	}))
+	stop := startMux(mux, make(chan error))
+
+	mux.Add(rings[0])
+	mux.AddRoute("test1", rings[1])
+	mux.Add(rings[2])
+	mux.Remove(rings[0])
+
+	if found, err := mux.Rings(); err != nil || fmt.Sprint(found) != fmt.Sprint(rings[1:]) {
+		t.Error(fmt.Sprintf("Expected %v, got %v %v", rings[1:], found, err))
+	}
+
+	mux.Write("test0")
+	mux.Write("test1")
+
+	// Items are written in the background.
+	for {
+		stats, err := mux.Stats()
+		if err != nil {
+			t.Fatal(fmt.Sprintf("Unexpected error: %v", err))
+		}
+
+		if stats[rings[1]].Forwarded == 1 && stats[rings[2]].Forwarded == 2 {
+			if stats[rings[2]].LastActive.IsZero() || stats[rings[2]].Errors != 0 {
+				t.Error(fmt.Sprintf("Unexpected stats %+v", stats[rings[2]]))
+			}
+			break
+		}
+	}
+
+	if n, err := mux.Len(); err != nil || n != 2 {
+		t.Error(fmt.Sprintf("Expected 2 rings, got %d %v", n, err))
+	}
+
+	stop()
+
+	if _, err := mux.Len(); !errors.Is(err, ringbuf.ErrClosed) {
+		t.Error(fmt.Sprintf("Expected ErrClosed from a stopped mux, got %v", err))
+	}
+
+	for _, ring := range rings {
+		ring.Cancel()
+	}
+}
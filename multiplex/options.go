@@ -0,0 +1,147 @@
+package multiplex
+
+import (
+	"github.com/dullgiulio/ringbuf"
+	"time"
+)
+
+// Configures a Mux in NewMux().
+type MuxOption func(m *Mux)
+
+// Route items by the key returned by key, see Mux.AddRoute().
+func WithRouteKey(key ringbuf.KeyFunc) MuxOption {
+	return func(m *Mux) {
+		m.key = key
+	}
+}
+
+// Wait at most timeout on returning from Run() for queued items to be
+// written, see DefaultDrainTimeout.
+func WithDrainTimeout(timeout time.Duration) MuxOption {
+	return func(m *Mux) {
+		m.drainWait = timeout
+	}
+}
+
+// Write items that no other ringbuf wants to ring.
+func WithDefaultRoute(ring *ringbuf.Ringbuf) MuxOption {
+	return func(m *Mux) {
+		m.fallback = ring
+	}
+}
+
+type targetOptions struct {
+	queueSize int
+	overflow  OverflowPolicy
+}
+
+// Configures how a Mux writes to a ringbuf in Add(), AddWithFilter()
+// and AddRoute().
+type TargetOption func(opts *targetOptions)
+
+// Queue up to size items for the ringbuf. Sizes below zero are taken
+// as zero. Without a queue, every item is handed to the ringbuf
+// directly: with OverflowDrop, almost all items are dropped then.
+func WithQueueSize(size int) TargetOption {
+	return func(opts *targetOptions) {
+		if size < 0 {
+			size = 0
+		}
+
+		opts.queueSize = size
+	}
+}
+
+// What to do when the queue of the ringbuf is full.
+func WithOverflow(policy OverflowPolicy) TargetOption {
+	return func(opts *targetOptions) {
+		opts.overflow = policy
+	}
+}
+
+// Configures a Demux in NewDemux().
+type DemuxOption func(d *Demux)
+
+// Create the output ringbuf with size items.
+func WithCapacity(size int64) DemuxOption {
+	return func(d *Demux) {
+		d.capacity = size
+		d.ring = nil
+		d.external = false
+	}
+}
+
+// Write to ring instead of creating an output ringbuf.
+func WithOutput(ring *ringbuf.Ringbuf) DemuxOption {
+	return func(d *Demux) {
+		d.ring = ring
+		d.external = true
+	}
+}
+
+// Options of the readers created by AddRing().
+func WithReaderOptions(opts ...ringbuf.ReaderOption) DemuxOption {
+	return func(d *Demux) {
+		d.readerOpts = opts
+	}
+}
+
+// Write every item as Tagged, telling which reader it came from.
+func WithTagging() DemuxOption {
+	return func(d *Demux) {
+		d.tagged = true
+	}
+}
+
+// Write items in the order given by less instead of as they arrive.
+// Items are held until every reader has an item to compare them with,
+// or for at most maxWait. A zero maxWait waits for stalled readers
+// forever. See ByTimestamp().
+func WithOrder(less func(a, b interface{}) bool, maxWait time.Duration) DemuxOption {
+	return func(d *Demux) {
+		d.less = less
+		d.maxWait = maxWait
+	}
+}
+
+// Hold up to size items of each reader when merging, see WithOrder()
+// and WithFairness(). Items a reader has out of order are put in order
+// within this window. Sizes below one are taken as one.
+func WithWindow(size int) DemuxOption {
+	return func(d *Demux) {
+		if size < 1 {
+			size = 1
+		}
+
+		d.window = size
+	}
+}
+
+// Give each reader a share of the output ringbuf by its weight, see
+// DemuxReader.SetWeight(). A reader that has as many items as its share
+// not yet read by the readers of the output ringbuf waits, instead of
+// pushing out items of other readers. Can be combined with WithOrder(),
+// but not with WithOutput().
+func WithFairness() DemuxOption {
+	return func(d *Demux) {
+		d.fair = true
+	}
+}
+
+// Send an event to eventCh each time a reader finishes on its own, for
+// example after EOF of its ringbuf. The reader is removed from the Demux.
+// Like the error channel of Run(), eventCh must be read while the Demux runs.
+func WithEvents(eventCh chan<- DemuxEvent) DemuxOption {
+	return func(d *Demux) {
+		d.eventCh = eventCh
+	}
+}
+
+// Send EOF() to the output ringbuf once the last reader has finished on
+// its own and all its items are written. Cannot be combined with
+// WithOutput().
+func WithEOF() DemuxOption {
+	return func(d *Demux) {
+		d.eof = true
+	}
+}
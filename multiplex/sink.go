@@ -0,0 +1,236 @@
+package multiplex
+
+import (
+	"fmt"
+	"github.com/dullgiulio/ringbuf"
+	"sync/atomic"
+	"time"
+)
+
+// Items queued for each ringbuf, unless set with WithQueueSize().
+const DefaultQueueSize = 64
+
+// How long Run() waits on returning for queued items to be written,
+// unless set with WithDrainTimeout().
+const DefaultDrainTimeout = time.Second
+
+// How often the lag of a ringbuf is looked at for StrategyLeastLoaded.
+const loadRefresh = 10 * time.Millisecond
+
+// What to do when the queue of a ringbuf is full.
+type OverflowPolicy int
+
+const (
+	// Wait for room in the queue. Holds up the whole Mux. The default.
+	OverflowBlock OverflowPolicy = iota
+	// Drop the item for this ringbuf.
+	OverflowDrop
+	// Stop writing to this ringbuf, as after Remove(), and send
+	// ErrQueueFull to the error channel.
+	OverflowDetach
+)
+
+// Items for a ringbuf, written by their own goroutine so that
+// a slow ringbuf does not hold up the others.
+type sink struct {
+	counters memberCounters
+	id       uint64
+	ring     *ringbuf.Ringbuf
+	queueCh  chan interface{}
+	overflow OverflowPolicy
+	// Lag of the ringbuf when last looked at, plus the items written
+	// since if it has readers. Negative if not known or the ringbuf is
+	// not running.
+	// Only changed by the sink goroutine, accessed atomically.
+	lag    int64
+	closed bool // Only used by the Mux main loop
+}
+
+// Unsafe. Must be called by the Mux main loop. Returns the sink of ring,
+// starting it if it's new.
+func (m *Mux) sink(errorCh chan<- error, ring *ringbuf.Ringbuf, opts []TargetOption) *sink {
+	if s, ok := m.sinks[ring]; ok {
+		return s
+	}
+
+	options := &targetOptions{queueSize: DefaultQueueSize}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	m.lastID++
+	s := &sink{
+		id:       m.lastID,
+		ring:     ring,
+		queueCh:  make(chan interface{}, options.queueSize),
+		overflow: options.overflow,
+		lag:      -1,
+	}
+	m.sinks[ring] = s
+
+	m.sinksWg.Add(1)
+	go m.runSink(errorCh, s)
+
+	return s
+}
+
+func (m *Mux) runSink(errorCh chan<- error, s *sink) {
+	defer m.sinksWg.Done()
+
+	// The Mux main loop must never wait for the ringbuf to tell its lag.
+	var (
+		refreshCh <-chan time.Time
+		lagging   bool // Written items add to the lag
+	)
+
+	if m.strategy == StrategyLeastLoaded {
+		ticker := time.NewTicker(loadRefresh)
+		defer ticker.Stop()
+
+		refreshCh = ticker.C
+		lagging = s.refresh()
+	}
+
+	for {
+		select {
+		case data, ok := <-s.queueCh:
+			if !ok {
+				return
+			}
+
+			if s.aborted(m) {
+				continue
+			}
+
+			err := s.ring.Write(data)
+			s.counters.record(err)
+
+			if err != nil {
+				m.report(errorCh, fmt.Errorf("%s: writing to ringbuf %p: %w", m, s.ring, err))
+			} else if lagging {
+				atomic.AddInt64(&s.lag, 1)
+			}
+		case <-refreshCh:
+			lagging = s.refresh()
+		}
+	}
+}
+
+// Returns true if Run() has stopped waiting for the queued items,
+// they are dropped then.
+func (s *sink) aborted(m *Mux) bool {
+	select {
+	case <-m.abortCh:
+		s.counters.record(errCanceled)
+		return true
+	default:
+		return false
+	}
+}
+
+// Must be called by the sink goroutine. Look at the lag of the ringbuf,
+// returns true if it has readers that will lag behind new items.
+func (s *sink) refresh() bool {
+	stats, err := s.ring.Stats()
+	if err != nil {
+		atomic.StoreInt64(&s.lag, -1)
+		return false
+	}
+
+	atomic.StoreInt64(&s.lag, stats.Lag)
+
+	return stats.Readers > 0
+}
+
+// Items the ringbuf has not delivered yet, counting the items queued.
+// Negative if not known.
+func (s *sink) load() int64 {
+	lag := atomic.LoadInt64(&s.lag)
+	if lag < 0 {
+		return lag
+	}
+
+	return lag + int64(len(s.queueCh))
+}
+
+// Send err unless the Mux is returning, nobody might read it then.
+func (m *Mux) report(errorCh chan<- error, err error) {
+	select {
+	case errorCh <- err:
+	case <-m.stopping:
+	}
+}
+
+// Unsafe. Must be called by the Mux main loop. Returns false if
+// the sink must be detached.
+func (m *Mux) enqueue(s *sink, data interface{}) bool {
+	if s.closed {
+		return true
+	}
+
+	if s.overflow == OverflowBlock {
+		s.queueCh <- data
+		return true
+	}
+
+	select {
+	case s.queueCh <- data:
+		return true
+	default:
+		atomic.AddInt64(&m.overflowed, 1)
+		return s.overflow != OverflowDetach
+	}
+}
+
+// Unsafe. Must be called by the Mux main loop.
+func (m *Mux) detach(errorCh chan<- error, s *sink) {
+	if s.closed {
+		return
+	}
+
+	if s.ring == m.fallback {
+		m.fallback = nil
+	}
+
+	m.remove(s.ring)
+	m.closeSink(s.ring)
+
+	errorCh <- fmt.Errorf("%s: ringbuf %p: %w", m, s.ring, ErrQueueFull)
+}
+
+// Unsafe. Must be called by the Mux main loop. The goroutine of the
+// sink exits after writing what is queued.
+func (m *Mux) closeSink(ring *ringbuf.Ringbuf) {
+	if s, ok := m.sinks[ring]; ok {
+		s.closed = true
+		close(s.queueCh)
+		delete(m.sinks, ring)
+	}
+}
+
+// Unsafe. Must be called by the Mux main loop. Wait for all queued
+// items to be written, but not longer than the drain timeout: the
+// sinks still writing then drop what is left. A ringbuf that never
+// takes its write does not hold up Run() this way.
+func (m *Mux) stop() {
+	close(m.stopping)
+
+	for ring := range m.sinks {
+		m.closeSink(ring)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		m.sinksWg.Wait()
+		close(drained)
+	}()
+
+	timer := time.NewTimer(m.drainWait)
+	defer timer.Stop()
+
+	select {
+	case <-drained:
+	case <-timer.C:
+		close(m.abortCh)
+	}
+}
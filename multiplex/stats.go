@@ -0,0 +1,152 @@
+package multiplex
+
+import (
+	"github.com/dullgiulio/ringbuf"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Counters of a ringbuf written by a Mux, or of a reader of a Demux.
+type MemberStats struct {
+	Forwarded  int64     // Items written
+	Errors     int64     // Items that could not be written
+	LastActive time.Time // When the last item was written, zero if never
+	Throttled  int64     // Items held back for other readers, see WithFairness()
+}
+
+// Accessed atomically.
+type memberCounters struct {
+	forwarded  int64
+	errors     int64
+	lastActive int64 // Unix nanoseconds
+	throttled  int64
+}
+
+func (c *memberCounters) record(err error) {
+	if err != nil {
+		atomic.AddInt64(&c.errors, 1)
+	} else {
+		atomic.AddInt64(&c.forwarded, 1)
+	}
+
+	atomic.StoreInt64(&c.lastActive, time.Now().UnixNano())
+}
+
+func (c *memberCounters) stats() MemberStats {
+	stats := MemberStats{
+		Forwarded: atomic.LoadInt64(&c.forwarded),
+		Errors:    atomic.LoadInt64(&c.errors),
+		Throttled: atomic.LoadInt64(&c.throttled),
+	}
+
+	if last := atomic.LoadInt64(&c.lastActive); last != 0 {
+		stats.LastActive = time.Unix(0, last)
+	}
+
+	return stats
+}
+
+// Get the ringbufs from the main loop, oldest first.
+func (m *Mux) members() ([]*sink, error) {
+	msg := newMuxMessageMembers()
+
+	if err := m.send(msg); err != nil {
+		return nil, err
+	}
+
+	return <-msg.membersCh, nil
+}
+
+// Unsafe. Must be called by the Mux main loop.
+func (m *Mux) sortedSinks() []*sink {
+	sinks := make([]*sink, 0, len(m.sinks))
+
+	for _, s := range m.sinks {
+		sinks = append(sinks, s)
+	}
+
+	sort.Slice(sinks, func(i, j int) bool {
+		return sinks[i].id < sinks[j].id
+	})
+
+	return sinks
+}
+
+// Returns the ringbufs the Mux writes to, in the order they were added.
+func (m *Mux) Rings() ([]*ringbuf.Ringbuf, error) {
+	sinks, err := m.members()
+	if err != nil {
+		return nil, err
+	}
+
+	rings := make([]*ringbuf.Ringbuf, len(sinks))
+	for i := range sinks {
+		rings[i] = sinks[i].ring
+	}
+
+	return rings, nil
+}
+
+// Returns the number of ringbufs the Mux writes to.
+func (m *Mux) Len() (int, error) {
+	sinks, err := m.members()
+	return len(sinks), err
+}
+
+// Returns the counters of each ringbuf the Mux writes to.
+func (m *Mux) Stats() (map[*ringbuf.Ringbuf]MemberStats, error) {
+	sinks, err := m.members()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[*ringbuf.Ringbuf]MemberStats, len(sinks))
+	for _, s := range sinks {
+		stats[s.ring] = s.counters.stats()
+	}
+
+	return stats, nil
+}
+
+// True from when Run() is called until it returns.
+func (m *Mux) Running() bool {
+	return atomic.LoadInt32(&m.running) != 0
+}
+
+// Get the readers from the main loop, oldest first.
+func (d *Demux) members() ([]*DemuxReader, error) {
+	msg := newDemuxMessageMembers()
+
+	if err := d.send(msg); err != nil {
+		return nil, err
+	}
+
+	return <-msg.membersCh, nil
+}
+
+// Returns the readers of the Demux, in the order they were added.
+func (d *Demux) Readers() ([]*DemuxReader, error) {
+	return d.members()
+}
+
+// Returns the number of readers of the Demux.
+func (d *Demux) Len() (int, error) {
+	readers, err := d.members()
+	return len(readers), err
+}
+
+// Returns the counters of each reader of the Demux.
+func (d *Demux) Stats() (map[*DemuxReader]MemberStats, error) {
+	readers, err := d.members()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[*DemuxReader]MemberStats, len(readers))
+	for _, dr := range readers {
+		stats[dr] = dr.counters.stats()
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,119 @@
+package multiplex
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+)
+
+// How a Mux distributes items between the ringbufs added with Add()
+// and AddWithFilter(). Routes added with AddRoute() are not affected.
+type Strategy int
+
+const (
+	// Write every item to all ringbufs.
+	StrategyBroadcast Strategy = iota
+	// Write each item to the next ringbuf in turn.
+	StrategyRoundRobin
+	// Write all items with the same key to the same ringbuf, keeping their
+	// order. The key is given by WithRouteKey(), or is the item itself.
+	// Adding or removing a ringbuf only moves the keys of that ringbuf.
+	StrategyHash
+	// Write each item to the ringbuf whose slowest reader lags the least.
+	StrategyLeastLoaded
+)
+
+// Returns the ringbufs that accept data.
+func (m *Mux) candidates(data interface{}) []*target {
+	targets := make([]*target, 0, len(m.rings))
+
+	for _, t := range m.rings {
+		if t.filter == nil || t.filter(data) {
+			targets = append(targets, t)
+		}
+	}
+
+	return targets
+}
+
+// Queue data according to the strategy. Returns false if no ringbuf
+// accepted data.
+func (m *Mux) distribute(data interface{}, queue func(s *sink)) bool {
+	targets := m.candidates(data)
+	if len(targets) == 0 {
+		return false
+	}
+
+	switch m.strategy {
+	case StrategyRoundRobin:
+		targets = targets[m.next%len(targets):][:1]
+		m.next++
+	case StrategyHash:
+		targets = []*target{m.hashTarget(targets, data)}
+	case StrategyLeastLoaded:
+		targets = []*target{leastLoaded(targets)}
+	}
+
+	for _, t := range targets {
+		queue(t.sink)
+	}
+
+	return true
+}
+
+// Rendezvous hashing: the target with the highest score for the key wins.
+func (m *Mux) hashTarget(targets []*target, data interface{}) *target {
+	key := data
+	if m.key != nil {
+		key = m.key(data)
+	}
+
+	best, bestScore := targets[0], uint64(0)
+	keyBytes := []byte(fmt.Sprint(key))
+
+	for _, t := range targets {
+		h := fnv.New64a()
+		h.Write(keyBytes)
+		binary.Write(h, binary.LittleEndian, t.id)
+
+		if score := h.Sum64(); score > bestScore {
+			best, bestScore = t, score
+		}
+	}
+
+	return best
+}
+
+// Returns the target whose ringbuf has the least lag, counting the
+// items still queued. Ringbufs that are not running, or whose lag is
+// not known yet, are only chosen if no other is.
+func leastLoaded(targets []*target) *target {
+	var (
+		best    *target
+		bestLag int64
+	)
+
+	for _, t := range targets {
+		lag := t.sink.load()
+		if lag < 0 {
+			continue
+		}
+
+		if best == nil || lag < bestLag {
+			best, bestLag = t, lag
+		}
+	}
+
+	if best == nil {
+		return targets[0]
+	}
+
+	return best
+}
+
+// Write items according to strategy, instead of to all ringbufs.
+func WithStrategy(strategy Strategy) MuxOption {
+	return func(m *Mux) {
+		m.strategy = strategy
+	}
+}
@@ -0,0 +1,183 @@
+package multiplex
+
+import (
+	"fmt"
+	"github.com/dullgiulio/ringbuf"
+	"strings"
+	"testing"
+	"time"
+)
+
+func runRings(n int) []*ringbuf.Ringbuf {
+	rings := make([]*ringbuf.Ringbuf, n)
+
+	for i := range rings {
+		rings[i] = ringbuf.NewRingbuf(100)
+		go rings[i].Run()
+	}
+
+	return rings
+}
+
+// Run mux, returns a function to cancel it and wait for all writes.
+func startMux(mux *Mux, errorCh chan error) func() {
+	done := make(chan bool)
+
+	go func() {
+		mux.Run(errorCh)
+		done <- true
+	}()
+
+	return func() {
+		mux.Cancel()
+		<-done
+	}
+}
+
+func retained(ring *ringbuf.Ringbuf) []interface{} {
+	var items []interface{}
+
+	for _, data := range ring.Retained() {
+		items = append(items, data)
+	}
+
+	return items
+}
+
+func TestMuxRoundRobin(t *testing.T) {
+	rings := runRings(3)
+
+	mux := NewMux(WithStrategy(StrategyRoundRobin))
+	stop := startMux(mux, make(chan error))
+
+	for _, ring := range rings {
+		mux.Add(ring)
+	}
+
+	for i := 0; i < 6; i++ {
+		mux.Write(i)
+	}
+	stop()
+
+	for i, ring := range rings {
+		if items := fmt.Sprint(retained(ring)); items != fmt.Sprint([]int{i, i + 3}) {
+			t.Error(fmt.Sprintf("Unexpected items in ring %d: %s", i, items))
+		}
+
+		ring.Cancel()
+	}
+}
+
+func TestMuxHash(t *testing.T) {
+	key := func(data interface{}) interface{} {
+		return strings.Split(data.(string), ":")[0]
+	}
+
+	rings := runRings(3)
+
+	mux := NewMux(WithStrategy(StrategyHash), WithRouteKey(key))
+	stop := startMux(mux, make(chan error))
+
+	for _, ring := range rings {
+		mux.Add(ring)
+	}
+
+	for i := 0; i < 30; i++ {
+		mux.Write(fmt.Sprintf("k%d:1", i))
+	}
+
+	// Removing a ringbuf only moves its own keys.
+	mux.Remove(rings[1])
+
+	for i := 0; i < 30; i++ {
+		mux.Write(fmt.Sprintf("k%d:2", i))
+	}
+	stop()
+
+	owner := make(map[string]int)
+	for i, ring := range rings {
+		for _, data := range retained(ring) {
+			parts := strings.Split(data.(string), ":")
+
+			if parts[1] == "1" {
+				owner[parts[0]] = i
+				continue
+			}
+
+			if i == 1 {
+				t.Error(fmt.Sprintf("Item '%s' written to removed ring", data))
+			}
+		}
+	}
+
+	for i, ring := range []*ringbuf.Ringbuf{rings[0], rings[2]} {
+		for _, data := range retained(ring) {
+			parts := strings.Split(data.(string), ":")
+
+			if parts[1] == "2" && owner[parts[0]] != 1 && owner[parts[0]] != i*2 {
+				t.Error(fmt.Sprintf("Key '%s' moved from ring %d to ring %d", parts[0], owner[parts[0]], i*2))
+			}
+		}
+	}
+
+	for _, ring := range rings {
+		ring.Cancel()
+	}
+}
+
+func TestMuxLeastLoaded(t *testing.T) {
+	rings := runRings(2)
+
+	// The first ring has a reader that does not keep up.
+	reader := ringbuf.NewReader(rings[0])
+	readCh := reader.ReadCh()
+
+	for {
+		if stats, _ := rings[0].Stats(); stats.Readers == 1 {
+			break
+		}
+	}
+
+	// Lagging more than the other ring can with all items queued.
+	for i := 0; i < 5; i++ {
+		rings[0].Write(fmt.Sprintf("test%d", i))
+	}
+
+	mux := NewMux(WithStrategy(StrategyLeastLoaded))
+	stop := startMux(mux, make(chan error))
+
+	mux.Add(rings[0])
+	mux.Add(rings[1])
+
+	// The lag of each ringbuf is looked at in the background.
+	deadline := time.Now().Add(time.Second)
+	for {
+		sinks, _ := mux.members()
+		if len(sinks) == 2 && sinks[0].load() >= 0 && sinks[1].load() >= 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the lag of both ringbufs to be known")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 0; i < 3; i++ {
+		mux.Write(i)
+	}
+	stop()
+
+	if items := fmt.Sprint(retained(rings[1])); items != "[0 1 2]" {
+		t.Error(fmt.Sprintf("Expected all items in the idle ring, got %s", items))
+	}
+
+	reader.Cancel()
+	for range readCh {
+	}
+
+	for _, ring := range rings {
+		ring.Cancel()
+	}
+}
@@ -0,0 +1,205 @@
+package ringbuf
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Configures a Ringbuf in NewRingbuf().
+type Option func(r *Ringbuf)
+
+// Call hooks on events of the ringbuf.
+func WithHooks(hooks *Hooks) Option {
+	return func(r *Ringbuf) {
+		r.hooks = hooks
+	}
+}
+
+// Only retain the newest item for each key, see NewRingbufCompact().
+func WithCompaction(key KeyFunc) Option {
+	return func(r *Ringbuf) {
+		r.key = key
+		r.latest = make(map[interface{}]Item)
+		r.superseded = make([]bool, r.size)
+	}
+}
+
+// Configures a Reader in NewReader() and Reconfigure().
+type ReaderOption func(opts *ReaderOptions)
+
+func WithNoStarve() ReaderOption {
+	return func(opts *ReaderOptions) {
+		opts.NoStarve = true
+	}
+}
+
+// Items must be acknowledged, see Reader.Ack(). A zero timeout
+// means DefaultAckTimeout.
+func WithAck(timeout time.Duration) ReaderOption {
+	return func(opts *ReaderOptions) {
+		opts.Ack = true
+		opts.AckTimeout = timeout
+	}
+}
+
+func WithLossless() ReaderOption {
+	return func(opts *ReaderOptions) {
+		opts.Lossless = true
+	}
+}
+
+func WithConflate(key KeyFunc) ReaderOption {
+	return func(opts *ReaderOptions) {
+		opts.Conflate = key
+	}
+}
+
+func WithRateLimit(limit RateLimit) ReaderOption {
+	return func(opts *ReaderOptions) {
+		opts.RateLimit = limit
+	}
+}
+
+func WithMaxBatch(n int) ReaderOption {
+	return func(opts *ReaderOptions) {
+		opts.MaxBatch = n
+	}
+}
+
+func WithIdleTimeout(timeout time.Duration) ReaderOption {
+	return func(opts *ReaderOptions) {
+		opts.IdleTimeout = timeout
+	}
+}
+
+// The buffer size is only used by SlowReaderBuffer.
+func WithSlowReader(policy SlowReaderPolicy, buffer int) ReaderOption {
+	return func(opts *ReaderOptions) {
+		opts.SlowReader = policy
+		opts.SlowReaderBuffer = buffer
+	}
+}
+
+func WithOnSlow(f func(reader *Reader, dropped int64)) ReaderOption {
+	return func(opts *ReaderOptions) {
+		opts.OnSlow = f
+	}
+}
+
+func WithStart(start StartPosition) ReaderOption {
+	return func(opts *ReaderOptions) {
+		opts.Start = start
+	}
+}
+
+// Start reading from sequence number seq, see StartSeq.
+func WithStartSeq(seq int64) ReaderOption {
+	return func(opts *ReaderOptions) {
+		opts.Start = StartSeq
+		opts.StartSeq = seq
+	}
+}
+
+func WithFilter(filter func(data interface{}) bool) ReaderOption {
+	return func(opts *ReaderOptions) {
+		opts.Filter = filter
+	}
+}
+
+func (r *Ringbuf) validate() error {
+	if r.latest != nil && r.key == nil {
+		return invalidOptions("compaction needs a KeyFunc")
+	}
+
+	return nil
+}
+
+func (o *ReaderOptions) validate() error {
+	switch {
+	case o.AckTimeout < 0:
+		return invalidOptions("negative AckTimeout")
+	case o.RateLimit.Rate < 0 || o.RateLimit.Burst < 0:
+		return invalidOptions("negative RateLimit")
+	case o.MaxBatch < 0:
+		return invalidOptions("negative MaxBatch")
+	case o.IdleTimeout < 0:
+		return invalidOptions("negative IdleTimeout")
+	case o.SlowReader < SlowReaderSkip || o.SlowReader > SlowReaderBuffer:
+		return invalidOptions("unknown SlowReader policy")
+	case o.SlowReader == SlowReaderBuffer && o.SlowReaderBuffer <= 0:
+		return invalidOptions("SlowReaderBuffer needs a positive buffer size")
+	case o.Start < StartOldest || o.Start > StartSeq:
+		return invalidOptions("unknown Start position")
+	case o.StartSeq < 0:
+		return invalidOptions("negative StartSeq")
+	case o.Lossless && o.SlowReader != SlowReaderSkip:
+		return invalidOptions("Lossless readers are never slow, no SlowReader policy applies")
+	}
+
+	return nil
+}
+
+type reconfigureRequest struct {
+	reader     *Reader
+	opts       []ReaderOption
+	responseCh chan error
+}
+
+// Change the options of this reader, also while it is reading.
+// Conflate can only be turned on or off before reading starts.
+func (r *Reader) Reconfigure(opts ...ReaderOption) error {
+	req := &reconfigureRequest{
+		reader:     r,
+		opts:       opts,
+		responseCh: make(chan error, 1),
+	}
+
+	if err := r.ring.send(newData(ringbufStatusReaderReconfigure, req)); err != nil {
+		return err
+	}
+
+	return <-req.responseCh
+}
+
+// Unsafe. Must be called by IO main loop. Changes are applied to the
+// current options here, so that concurrent changes are never lost.
+func (r *Ringbuf) reconfigure(reader *Reader, changes []ReaderOption) error {
+	old := reader.options()
+
+	opts := *old
+	for _, change := range changes {
+		change(&opts)
+	}
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	if reader.started && (old.Conflate == nil) != (opts.Conflate == nil) {
+		return invalidOptions("cannot turn Conflate on or off while reading")
+	}
+
+	if _, ok := r.readersStarving[reader]; ok && old.Lossless != opts.Lossless {
+		if opts.Lossless {
+			atomic.AddInt32(&r.lossless, 1)
+		} else {
+			atomic.AddInt32(&r.lossless, -1)
+		}
+	}
+
+	if !opts.Ack {
+		reader.unacked = make(map[int64]time.Time)
+	}
+
+	reader.opts.Store(&opts)
+
+	// A starving reader has to notice its new options.
+	if r.readersStarving[reader] {
+		r.readersStarving[reader] = false
+		reader.wakeup()
+	}
+
+	r.unpark()
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package ringbuf
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestInvalidReaderOptions(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Expected panic()")
+		}
+	}()
+
+	NewReader(NewRingbuf(3), WithSlowReader(SlowReaderBuffer, 0))
+}
+
+func TestCreateInvalid(t *testing.T) {
+	if _, err := CreateRingbuf(0); !errors.Is(err, ErrInvalidOptions) {
+		t.Error(fmt.Sprintf("Expected ErrInvalidOptions for size 0, got %v", err))
+	}
+
+	if _, err := CreateRingbuf(3, WithCompaction(nil)); !errors.Is(err, ErrInvalidOptions) {
+		t.Error(fmt.Sprintf("Expected ErrInvalidOptions for compaction without key, got %v", err))
+	}
+
+	ring, err := CreateRingbuf(3)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Unexpected error: %v", err))
+	}
+
+	if _, err := CreateReader(ring, WithSlowReader(SlowReaderBuffer, 0)); !errors.Is(err, ErrInvalidOptions) {
+		t.Error(fmt.Sprintf("Expected ErrInvalidOptions, got %v", err))
+	}
+}
+
+func TestReconfigureConcurrent(t *testing.T) {
+	ring := NewRingbuf(3)
+	reader := NewReader(ring)
+
+	go ring.Run()
+
+	var wg sync.WaitGroup
+	for _, opt := range []ReaderOption{WithMaxBatch(2), WithNoStarve()} {
+		wg.Add(1)
+		go func(opt ReaderOption) {
+			defer wg.Done()
+
+			if err := reader.Reconfigure(opt); err != nil {
+				t.Error(err)
+			}
+		}(opt)
+	}
+
+	wg.Wait()
+
+	if opts := reader.GetOptions(); opts.MaxBatch != 2 || !opts.NoStarve {
+		t.Error(fmt.Sprintf("Expected both changes, got %+v", opts))
+	}
+
+	ring.Cancel()
+}
+
+func TestReconfigure(t *testing.T) {
+	ring := NewRingbuf(3)
+	reader := NewReader(ring)
+	readCh := reader.ReadCh()
+
+	go ring.Run()
+
+	ring.Write("test0")
+
+	if s := <-readCh; s != "test0" {
+		t.Error("Expected string not found")
+	}
+
+	if err := reader.Reconfigure(WithMaxBatch(-1)); err == nil {
+		t.Error("Expected error for invalid options")
+	}
+
+	if err := reader.Reconfigure(WithConflate(keyBeforeEquals)); err == nil {
+		t.Error("Expected error turning on Conflate while reading")
+	}
+
+	// The starving reader must give up once told not to starve.
+	if err := reader.Reconfigure(WithNoStarve()); err != nil {
+		t.Error(err)
+	}
+
+	if s := <-readCh; s != nil {
+		t.Error("Unexpected read from closed channel")
+	}
+
+	if !reader.GetOptions().NoStarve {
+		t.Error("Expected NoStarve to be set")
+	}
+
+	ring.Cancel()
+}
+
+func TestReaderStartAndFilter(t *testing.T) {
+	ring := NewRingbuf(10)
+	go ring.Run()
+
+	for i := 0; i < 6; i++ {
+		ring.Write(i)
+	}
+
+	odd := func(data interface{}) bool {
+		return data.(int)%2 == 1
+	}
+
+	newest := NewReader(ring, WithStart(StartNewest))
+	newestCh := newest.ReadCh()
+
+	var found []interface{}
+	for data := range NewReader(ring, WithStartSeq(2), WithFilter(odd), WithNoStarve()).All() {
+		found = append(found, data)
+	}
+
+	if fmt.Sprint(found) != "[3 5]" {
+		t.Error(fmt.Sprintf("Expected [3 5], got %v", found))
+	}
+
+	// Wait for the reader to join before the next write.
+	for {
+		if stats, _ := ring.Stats(); stats.Readers == 1 {
+			break
+		}
+	}
+
+	ring.Write(6)
+
+	if data := <-newestCh; data != 6 {
+		t.Error(fmt.Sprintf("Expected only new items, got %v", data))
+	}
+
+	newest.Cancel()
+	for range newestCh {
+	}
+
+	ring.Cancel()
+}
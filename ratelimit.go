@@ -0,0 +1,99 @@
+package ringbuf
+
+import "time"
+
+// Limits the items per second a reader delivers to its user.
+// The zero value means no limit.
+type RateLimit struct {
+	Rate  float64 // Items per second
+	Burst int     // Items that can be delivered at once
+}
+
+// Token bucket enforcing a RateLimit. Not safe for concurrent use.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+
+	b.last = now
+}
+
+// Time to wait from now until an item can be delivered.
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Account for an item that is being delivered.
+func (b *tokenBucket) take(now time.Time) {
+	b.refill(now)
+	b.tokens--
+}
+
+// Start over if the rate limit has been reconfigured.
+func (r *Reader) updateLimiter() {
+	limit := r.options().RateLimit
+	if limit == r.limit {
+		return
+	}
+
+	r.limit = limit
+	r.limiter = nil
+
+	if limit.Rate > 0 {
+		r.limiter = newTokenBucket(limit)
+	}
+}
+
+// Wait until the rate limit allows to deliver an item. Returns
+// false if the reader was canceled in the meantime.
+func (r *Reader) pace() bool {
+	r.updateLimiter()
+
+	if r.limiter == nil {
+		return true
+	}
+
+	if wait := r.limiter.delay(time.Now()); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-r.canceled:
+			return false
+		}
+	}
+
+	r.limiter.take(time.Now())
+
+	return true
+}
@@ -0,0 +1,92 @@
+package ringbuf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(RateLimit{Rate: 10, Burst: 2})
+
+	for i := 0; i < 2; i++ {
+		if wait := b.delay(now); wait != 0 {
+			t.Error(fmt.Sprintf("Expected no wait within burst, got %s", wait))
+		}
+		b.take(now)
+	}
+
+	if wait := b.delay(now); wait != 100*time.Millisecond {
+		t.Error(fmt.Sprintf("Expected to wait 100ms, got %s", wait))
+	}
+
+	if wait := b.delay(now.Add(100 * time.Millisecond)); wait != 0 {
+		t.Error(fmt.Sprintf("Expected no wait after refill, got %s", wait))
+	}
+}
+
+func TestRateLimitBatch(t *testing.T) {
+	ring := NewRingbuf(10)
+	reader := NewReader(ring, WithRateLimit(RateLimit{Rate: 100, Burst: 1}), WithMaxBatch(3))
+
+	go ring.Run()
+
+	for i := 0; i < 5; i++ {
+		ring.Write(fmt.Sprintf("test%d", i))
+	}
+	ring.EOF()
+
+	start := time.Now()
+	i := 0
+
+	for data := range reader.ReadCh() {
+		if exp := fmt.Sprintf("test%d", i); data != exp {
+			t.Error(fmt.Sprintf("Expected value %s, got '%s'", exp, data))
+		}
+		i++
+	}
+
+	if i != 5 {
+		t.Error(fmt.Sprintf("Expected 5 items, got %d", i))
+	}
+
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Error(fmt.Sprintf("Rate limit not enforced, read all in %s", elapsed))
+	}
+
+	ring.Cancel()
+}
+
+func TestRateLimitCancel(t *testing.T) {
+	limit := WithRateLimit(RateLimit{Rate: 0.1, Burst: 1})
+
+	for _, opts := range [][]ReaderOption{{limit}, {limit, WithConflate(keyBeforeEquals)}} {
+		ring := NewRingbuf(10)
+		reader := NewReader(ring, opts...)
+		readCh := reader.ReadCh()
+
+		go ring.Run()
+
+		ring.Write("test0")
+		ring.Write("test1")
+
+		if data := <-readCh; data != "test0" {
+			t.Error(fmt.Sprintf("Expected value test0, got '%s'", data))
+		}
+
+		// The next item is due in ten seconds.
+		reader.Cancel()
+
+		select {
+		case data, ok := <-readCh:
+			if ok {
+				t.Error(fmt.Sprintf("Unexpected read after Cancel: '%s'", data))
+			}
+		case <-time.After(time.Second):
+			t.Error("Cancel did not interrupt the rate limit")
+		}
+
+		ring.Cancel()
+	}
+}
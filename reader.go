@@ -1,5 +1,10 @@
 package ringbuf
 
+import (
+	"sync/atomic"
+	"time"
+)
+
 type Reader struct {
 	ring   *Ringbuf
 	pos    int64
@@ -7,78 +12,358 @@ type Reader struct {
 	// Channel to write to.
 	outputCh chan Data
 	starving chan bool
+	canceled chan struct{} // Closed by the ringbuf main loop on Cancel()
+	probeCh  chan struct{} // See settle()
 	readCh   chan interface{}
-	opts     *ReaderOptions
+	itemCh   chan Item
+	opts     atomic.Pointer[ReaderOptions]
+	// Sequence numbers of unacknowledged items and when they are due
+	// for redelivery. Only used by the ringbuf main loop.
+	unacked map[int64]time.Time
+	// Items to deliver before reading from the ringbuf.
+	replay   []Item // Not passed to the user of the reader forked from
+	snapshot []Item
+	overflow []Item
+	// Items sent to the reading goroutine, see inflight().
+	served  []Data
+	started bool
+	// Counters, see Stats(). Only used by the ringbuf main loop.
+	delivered int64
+	dropped   int64
+	// Counters kept by the reading goroutine, accessed atomically.
+	conflated int64
+	buffered  int64
+	next      int64 // After the last item passed to the user, -1 before
+	seeks     int64 // Changed only by the ringbuf main loop
+	offering  int64 // Item being passed to the user plus one, 0 if none
+	// Only used by the reading goroutine.
+	limiter  *tokenBucket
+	limit    RateLimit
+	joined   bool
+	polled   []Data // Received by TryNext() but not returned yet
+	finished bool   // TryNext() has left the ringbuf
+	// Read with TryNext(). Set before the ringbuf main loop first
+	// sees the reader, only read by it afterwards.
+	polling bool
+	// A polling reader that the ringbuf main loop has already
+	// forgotten on Cancel(). Only used by the ringbuf main loop.
+	left bool
+	// Why reading stopped, if not because of EOF or Cancel().
+	err error
 }
 
 type ReaderOptions struct {
 	NoStarve bool
+	// Items must be acknowledged with Ack(). Unacknowledged items
+	// are delivered again after AckTimeout.
+	Ack        bool
+	AckTimeout time.Duration
+	// The ringbuf will not overwrite items this reader has not
+	// read yet or, in Ack mode, not acknowledged yet.
+	Lossless bool
+	// While the user is not reading, keep only the newest pending
+	// item for each key.
+	Conflate KeyFunc
+	// Deliver at most this many items per second.
+	RateLimit RateLimit
+	// Get up to this many items from the ringbuf at once.
+	MaxBatch int
+	// Stop reading with ErrTimeout if there is no new data for this long.
+	IdleTimeout time.Duration
+	// What to do when the writer overtakes this reader.
+	SlowReader       SlowReaderPolicy
+	SlowReaderBuffer int
+	// Called every time this reader loses items, like Hooks.OnOverrun.
+	OnSlow func(reader *Reader, dropped int64)
+	// Where to start reading.
+	Start    StartPosition
+	StartSeq int64
+	// Only deliver items for which Filter is true. Called by the
+	// ringbuf main loop, it must be fast.
+	Filter func(data interface{}) bool
+}
+
+// An item together with its sequence number in the ringbuf.
+type Item struct {
+	Seq  int64
+	Data interface{}
+}
+
+// Create a reader of r. Panics if the options are not valid.
+func NewReader(r *Ringbuf, opts ...ReaderOption) *Reader {
+	reader, err := CreateReader(r, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return reader
+}
+
+// Like NewReader(), but returns an ErrInvalidOptions error instead of panicking.
+func CreateReader(r *Ringbuf, opts ...ReaderOption) (*Reader, error) {
+	options := &ReaderOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	return newReader(r, options), nil
 }
 
-func NewReader(r *Ringbuf) *Reader {
-	return &Reader{
+func newReader(r *Ringbuf, options *ReaderOptions) *Reader {
+	reader := &Reader{
 		ring: r,
-		// Do not buffer the reader's outputCh, that will make
-		// contention unbearably slow.
-		outputCh: make(chan Data),
-		starving: make(chan bool),
+		// There is at most one response pending, the ringbuf
+		// must never wait for us to receive it.
+		outputCh: make(chan Data, 1),
+		// The ringbuf must never wait for us to be woken up.
+		starving: make(chan bool, 1),
+		canceled: make(chan struct{}),
+		probeCh:  make(chan struct{}),
 		readCh:   make(chan interface{}),
-		opts:     &ReaderOptions{},
+		itemCh:   make(chan Item),
+		unacked:  make(map[int64]time.Time),
+		next:     -1,
 	}
+	reader.opts.Store(options)
+
+	return reader
 }
 
 // Warning: this is not a safe operation. Do not set the configuration
-// options after aquiring a reading channel with ReadCh().
+// options after aquiring a reading channel with ReadCh(). Use
+// Reconfigure() instead.
 func (r *Reader) SetOptions(opts *ReaderOptions) {
-	r.opts = opts
+	r.opts.Store(opts)
 }
 
+// Returns a copy of the current options.
 func (r *Reader) GetOptions() *ReaderOptions {
-	return r.opts
+	opts := *r.options()
+	return &opts
+}
+
+// Options must never be modified once set, they are replaced instead.
+func (r *Reader) options() *ReaderOptions {
+	return r.opts.Load()
 }
 
 func (r *Reader) ReadCh() <-chan interface{} {
-	go func() {
-		for {
-			// Request data from the ringbuf. Will reply on outputCh when ready.
-			r.ring.dataCh <- newData(ringbufStatusReader, r)
-
-			// Wait from a response from the ringbuf.
-			msg := <-r.outputCh
-
-			switch msg.status {
-			case ringbufStatusOK:
-				// Write data to our user. Might block.
-				r.readCh <- msg.data
-			case ringbufStatusEOF:
-				// Signal the ringbuf that we are not using it any more.
-				r.ring.dataCh <- newData(ringbufStatusReaderCancel, r)
-				return
-			case ringbufStatusStarving:
-				if r.opts.NoStarve {
-					r.ring.dataCh <- newData(ringbufStatusReaderCancel, r)
-					<-r.starving
-					return
-				}
-
-				// The ringbuf has no data. Will signal on this channel that
-				// it is ready to serve us if we repeat the request.
+	r.join()
+	go r.run(false)
+	return r.readCh
+}
+
+// Like ReadCh(), but every item comes with its sequence number.
+// Readers in Ack mode must use this to know what to acknowledge.
+func (r *Reader) ReadItemCh() <-chan Item {
+	r.join()
+	go r.run(true)
+	return r.itemCh
+}
+
+// From now on the ringbuf must not quit before we ask for data.
+func (r *Reader) join() {
+	if !r.joined {
+		r.joined = true
+		atomic.AddInt32(&r.ring.joining, 1)
+	}
+}
+
+// Write data to our user, either as Item or plain data. Might block.
+// Items served before a seek are dropped instead.
+func (r *Reader) send(msg Data, items bool) {
+	readCh, itemCh := r.readCh, r.itemCh
+	if items {
+		readCh = nil
+	} else {
+		itemCh = nil
+	}
+
+	for {
+		// Offered before looking at the seeks, see settle().
+		r.offer(msg.seq)
+
+		if r.stale(msg) {
+			r.withdraw(1)
+			return
+		}
+
+		select {
+		case readCh <- msg.data:
+		case itemCh <- Item{Seq: msg.seq, Data: msg.data}:
+		case <-r.probeCh:
+			// Still waiting for the user, see settle().
+			continue
+		}
+
+		break
+	}
+
+	r.passed(msg.seq)
+}
+
+// Items received from the ringbuf, either one or a batch.
+func received(msg Data) []Data {
+	if msg.status == ringbufStatusBatch {
+		return msg.data.([]Data)
+	}
+
+	return []Data{msg}
+}
+
+// Write all items to our user, respecting the rate limit. The ringbuf
+// has counted them as buffered already.
+func (r *Reader) sendAll(msgs []Data, items bool) {
+	for i, msg := range msgs {
+		if !r.pace() {
+			// Canceled, the rest will never be delivered.
+			r.withdraw(len(msgs) - i)
+			return
+		}
+
+		r.send(msg, items)
+	}
+}
+
+// Request data from the ringbuf and wait for its response.
+func (r *Reader) request() Data {
+	if err := r.ring.send(newData(ringbufStatusReader, r)); err != nil {
+		r.err = err
+		return newData(ringbufStatusEOF, nil)
+	}
+
+	return <-r.outputCh
+}
+
+// Signal the ringbuf that we are not using it any more. If it's
+// not running, clean up on our own.
+func (r *Reader) leave() {
+	if err := r.ring.send(newData(ringbufStatusReaderCancel, r)); err != nil {
+		r.cleanup()
+	}
+}
+
+// Some items have to be redelivered if they are not acknowledged
+// in time. The returned timer, if any, fires when they are due.
+func redeliveryTimer(msg Data) *time.Timer {
+	if wait, ok := msg.data.(time.Duration); ok {
+		return time.NewTimer(wait)
+	}
+
+	return nil
+}
+
+func timerCh(timer *time.Timer) <-chan time.Time {
+	if timer == nil {
+		return nil
+	}
+
+	return timer.C
+}
+
+func (r *Reader) run(items bool) {
+	if r.options().Conflate != nil {
+		r.runConflate(items)
+		return
+	}
+
+	// When the last item was received, see idleCh().
+	active := time.Now()
+
+	for {
+		msg := r.request()
+
+		switch msg.status {
+		case ringbufStatusOK, ringbufStatusBatch:
+			active = time.Now()
+			r.sendAll(received(msg), items)
+		case ringbufStatusEOF:
+			r.leave()
+			return
+		case ringbufStatusStarving:
+			if r.options().NoStarve {
+				r.leave()
 				<-r.starving
-				continue
+				return
 			}
+
+			// The ringbuf has no data. Will signal on this channel that
+			// it is ready to serve us if we repeat the request. Also ask
+			// again when unacknowledged items are due.
+			timer := redeliveryTimer(msg)
+
+			select {
+			case <-r.starving:
+			case <-timerCh(timer):
+			case <-r.idleCh(active):
+				r.timeout()
+				return
+			}
+
+			if timer != nil {
+				timer.Stop()
+			}
+			continue
 		}
-	}()
+	}
+}
 
-	return r.readCh
+// Fires when the reader has been idle for too long since it received
+// an item at active, if ever. Waking up for nothing does not count.
+func (r *Reader) idleCh(active time.Time) <-chan time.Time {
+	if r.options().IdleTimeout <= 0 {
+		return nil
+	}
+
+	return time.After(time.Until(active.Add(r.options().IdleTimeout)))
 }
 
-func (r *Reader) Cancel() {
-	r.ring.dataCh <- newData(ringbufStatusReaderRequestCancel, r)
+// Stop reading because no data arrived in time.
+func (r *Reader) timeout() {
+	r.err = ErrTimeout
+	r.leave()
+}
+
+// The reason reading has stopped, or nil for EOF or Cancel(). Only
+// valid after the channel returned by ReadCh() has been closed.
+// It is ErrTimeout, ErrClosed or an *ErrOverrun.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+func (r *Reader) Cancel() error {
+	return r.ring.send(newData(ringbufStatusReaderRequestCancel, r))
+}
+
+// Unsafe. Must be called by IO main loop. Mark reader as canceled,
+// waking it up if it waits for its rate limit.
+func (r *Ringbuf) cancel(reader *Reader) {
+	r.readersCanceled[reader] = true
+
+	select {
+	case <-reader.canceled:
+	default:
+		close(reader.canceled)
+	}
+}
+
+// Unsafe. Must be called by IO main loop. Tell a starving reader
+// to request data again, unless it has been told already.
+func (r *Reader) wakeup() {
+	select {
+	case r.starving <- true:
+	default:
+	}
 }
 
 func (r *Reader) cleanup() {
 	// Close channels.
 	close(r.readCh)
+	close(r.itemCh)
 	close(r.outputCh)
 	close(r.starving)
 }
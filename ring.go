@@ -5,10 +5,49 @@ func (r *Ringbuf) write(data interface{}) {
 	if r.pos >= r.size {
 		r.pos = 0
 		r.cycles++
+		r.onWrap()
+	}
+
+	if r.written() >= r.size {
+		r.evict(r.written() - r.size)
+
+		if r.key != nil {
+			r.forget(r.written() - r.size)
+		}
 	}
 
 	r.data[r.pos] = data
 	r.pos++
+
+	if r.key != nil {
+		r.compact(r.written()-1, data)
+	}
+}
+
+// Number of items written so far. This is also the sequence
+// number of the next item to be written.
+func (r *Ringbuf) written() int64 {
+	return r.cycles*r.size + r.pos
+}
+
+// Sequence number of the oldest item still in the ringbuf.
+func (r *Ringbuf) oldest() int64 {
+	if w := r.written(); w > r.size {
+		return w - r.size
+	}
+
+	return 0
+}
+
+// Sequence number of the next item this reader will read.
+func (r *Reader) seq() int64 {
+	return r.cycles*r.ring.size + r.pos
+}
+
+// Move the reader to the item with sequence number seq.
+func (r *Reader) setSeq(seq int64) {
+	r.cycles = seq / r.ring.size
+	r.pos = seq % r.ring.size
 }
 
 func (r *Reader) read() (interface{}, bool) {
@@ -49,6 +88,7 @@ func (r *Reader) read() (interface{}, bool) {
 
 		// Two or more cycles behind, cannot rescue this
 		// Instead, skip to where the ring starts now
+		r.dropped += r.ring.cycles*r.ring.size + 1 - r.seq()
 		r.cycles = r.ring.cycles
 		r.pos = 1
 		return r.ring.data[0], false
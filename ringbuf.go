@@ -1,14 +1,30 @@
 package ringbuf
 
+import (
+	"sync/atomic"
+	"time"
+)
+
 type Ringbuf struct {
 	data            []interface{} // type that is stored
 	pos             int64         // position for writing
 	cycles          int64
 	size            int64
 	dataCh          chan Data
+	done            chan struct{} // Closed when Run() returns
+	closed          int32         // Set after EOF(), accessed atomically
 	readersStarving map[*Reader]bool
 	readersCanceled map[*Reader]bool
 	readOnly        bool
+	joining         int32  // Readers yet to send their first request, accessed atomically
+	lossless        int32  // Number of lossless readers, accessed atomically
+	parked          []Data // Writes waiting for lossless readers
+	key             KeyFunc
+	latest          map[interface{}]Item // Newest item for each key
+	superseded      []bool               // Slots with a newer item for the same key
+	hooks           *Hooks
+	kept            map[int64]int // Readers keeping an overwritten item, by seq
+	hookQueue       hookQueue
 }
 
 type Write struct {
@@ -17,31 +33,96 @@ type Write struct {
 	responseCh chan<- bool // Where to confirm the success/failure of the write
 }
 
-func NewRingbuf(size int64) *Ringbuf {
+// Create a ringbuf of size items. Panics if size or the options are not valid.
+func NewRingbuf(size int64, opts ...Option) *Ringbuf {
+	r, err := CreateRingbuf(size, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// Like NewRingbuf(), but returns an ErrInvalidOptions error instead of panicking.
+func CreateRingbuf(size int64, opts ...Option) (*Ringbuf, error) {
 	if size <= 0 {
-		panic("Tried to allocate a zero-sized Ringbuf")
+		return nil, invalidOptions("size must be positive")
 	}
 
-	return &Ringbuf{
+	r := &Ringbuf{
 		data:            make([]interface{}, size),
 		size:            size,
 		dataCh:          make(chan Data),
+		done:            make(chan struct{}),
 		readersStarving: make(map[*Reader]bool),
 		readersCanceled: make(map[*Reader]bool),
+		hookQueue:       hookQueue{readyCh: make(chan bool, 1)},
+	}
+
+	for _, opt := range opts {
+		opt(r)
 	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
 }
 
-// Safe write via channel.
-func (r *Ringbuf) Write(data interface{}) {
-	r.dataCh <- newData(ringbufStatusWrite, data)
+// Number of items the ringbuf holds.
+func (r *Ringbuf) Size() int64 {
+	return r.size
 }
 
-func (r *Ringbuf) Cancel() {
-	r.dataCh <- newData(ringbufStatusEOF, nil)
+// Send a message to the main loop, unless it has returned.
+func (r *Ringbuf) send(msg Data) error {
+	select {
+	case r.dataCh <- msg:
+		return nil
+	case <-r.done:
+		return ErrClosed
+	}
 }
 
-func (r *Ringbuf) EOF() {
-	r.dataCh <- newData(ringbufStatusStarving, nil)
+// Safe write via channel. Returns ErrClosed after EOF() or Cancel().
+func (r *Ringbuf) Write(data interface{}) error {
+	if atomic.LoadInt32(&r.closed) != 0 {
+		return ErrClosed
+	}
+
+	if atomic.LoadInt32(&r.lossless) == 0 {
+		return r.send(newData(ringbufStatusWrite, data))
+	}
+
+	// Lossless readers might hold this write back, wait until it's done.
+	responseCh := make(chan bool, 1)
+	w := &Write{data: data, responseCh: responseCh}
+
+	if err := r.send(newData(ringbufStatusWriteWait, w)); err != nil {
+		return err
+	}
+
+	select {
+	case ok := <-responseCh:
+		if !ok {
+			return ErrClosed
+		}
+
+		return nil
+	case <-r.done:
+		return ErrClosed
+	}
+}
+
+func (r *Ringbuf) Cancel() error {
+	return r.send(newData(ringbufStatusEOF, nil))
+}
+
+// Stop writing. Readers get EOF after reading what is left.
+func (r *Ringbuf) EOF() error {
+	atomic.StoreInt32(&r.closed, 1)
+	return r.send(newData(ringbufStatusStarving, nil))
 }
 
 func (r *Ringbuf) wakeupStarving() {
@@ -51,19 +132,131 @@ func (r *Ringbuf) wakeupStarving() {
 			r.readersStarving[reader] = false
 			// Tell the reader we have new data, but it
 			// will have to be requested again.
-			reader.starving <- true
+			reader.wakeup()
 		}
 	}
 }
 
+// Unsafe. Must be called by IO main loop.
+func (r *Ringbuf) serve(reader *Reader) (Data, bool) {
+	if !reader.started {
+		r.start(reader)
+	}
+
+	if reader.options().Ack {
+		if msg, ok := r.redeliver(reader); ok {
+			return msg, true
+		}
+	}
+
+	for len(reader.replay) > 0 {
+		item := reader.replay[0]
+		reader.replay[0], reader.replay = Item{}, reader.replay[1:]
+
+		if reader.wants(item.Data) {
+			return r.deliver(reader, item.Seq, item.Data), true
+		}
+	}
+
+	for {
+		item, ok := r.nextSnapshot(reader)
+		if !ok {
+			break
+		}
+
+		if reader.wants(item.Data) {
+			return r.deliver(reader, item.Seq, item.Data), true
+		}
+	}
+
+	for {
+		item, ok := reader.nextOverflow()
+		if !ok {
+			break
+		}
+
+		if reader.wants(item.Data) {
+			return r.deliver(reader, item.Seq, item.Data), true
+		}
+	}
+
+	if reader.seq() < r.oldest() {
+		if msg, ok := r.overrun(reader); ok {
+			return msg, true
+		}
+	}
+
+	for {
+		seq := reader.seq()
+		dropped := reader.dropped
+
+		data, ok := reader.read()
+
+		if reader.dropped > dropped {
+			r.slowReader(reader, reader.dropped-dropped)
+		}
+
+		if !ok || data == nil {
+			return Data{}, false
+		}
+
+		// Skip items that have a newer version.
+		if r.key != nil && r.superseded[seq%r.size] {
+			continue
+		}
+
+		if !reader.wants(data) {
+			continue
+		}
+
+		return r.deliver(reader, seq, data), true
+	}
+}
+
+// Unsafe. Must be called by IO main loop. Add more items to msg
+// if the reader wants them in batches.
+func (r *Ringbuf) serveBatch(reader *Reader, msg Data) Data {
+	if reader.options().MaxBatch <= 1 || msg.status != ringbufStatusOK {
+		return msg
+	}
+
+	batch := []Data{msg}
+
+	for len(batch) < reader.options().MaxBatch {
+		next, ok := r.serve(reader)
+		if !ok || next.status != ringbufStatusOK {
+			break
+		}
+
+		batch = append(batch, next)
+	}
+
+	return newData(ringbufStatusBatch, batch)
+}
+
+// Unsafe. Must be called by IO main loop.
+func (r *Ringbuf) deliver(reader *Reader, seq int64, data interface{}) Data {
+	if reader.options().Ack {
+		reader.unacked[seq] = time.Now().Add(reader.ackTimeout())
+	}
+
+	reader.delivered++
+
+	return newDataSeq(ringbufStatusOK, data, seq)
+}
+
 func (r *Ringbuf) Run() {
-	defer close(r.dataCh)
+	defer close(r.done)
+	// Let pending hooks run, then stop.
+	defer close(r.hookQueue.readyCh)
 
 	for msg := range r.dataCh {
 		switch msg.status {
 		// Hard quitting of the ringbuf runner.
 		case ringbufStatusEOF:
-			if len(r.readersStarving) == 0 {
+			r.rejectParked()
+
+			if len(r.readersStarving) == 0 && atomic.LoadInt32(&r.joining) == 0 {
 				// When we have exhausted all readers, we can exit.
 				// This has the potential to keep this ringbuf open forever
 				// if the readers misbehave and don't unsubscribe correctly.
@@ -75,44 +268,88 @@ func (r *Ringbuf) Run() {
 			r.wakeupStarving()
 		// Writing finished, switch to read-only mode.
 		case ringbufStatusStarving:
+			if !r.readOnly {
+				r.onEOF()
+			}
+
 			r.readOnly = true
+			r.rejectParked()
 
 			// No more data for starving readers.
 			// Wake them up and they'll ask for data, then they'll get EOF.
 			r.wakeupStarving()
 		// Normal writing.
-		case ringbufStatusWrite:
-			if !r.readOnly {
-				r.write(msg.data)
+		case ringbufStatusWrite, ringbufStatusWriteWait:
+			if r.readOnly {
+				confirmWrite(msg, false)
+				continue
+			}
 
-				// Readers should now try again reading.
-				r.wakeupStarving()
+			// Keep the write until lossless readers make room for it.
+			if len(r.parked) > 0 || r.full() {
+				r.parked = append(r.parked, msg)
+				continue
 			}
+
+			r.write(writeData(msg))
+			confirmWrite(msg, true)
+
+			// Readers should now try again reading.
+			r.wakeupStarving()
+		case ringbufStatusTryWrite:
+			w := msg.data.(*Write)
+			w.responseCh <- r.tryWrite(w)
 			// Reader requesting data.
 		case ringbufStatusReader:
 			// This is a cast to a pointer, never fails.
 			reader := msg.data.(*Reader)
 
+			// Canceled while polling, it must not join again.
+			if reader.left {
+				reader.outputCh <- newData(ringbufStatusEOF, nil)
+				continue
+			}
+
+			if _, ok := r.readersStarving[reader]; !ok {
+				atomic.AddInt32(&r.joining, -1)
+
+				if reader.options().Lossless {
+					atomic.AddInt32(&r.lossless, 1)
+				}
+
+				r.readersStarving[reader] = false
+				r.onReaderJoin(reader)
+			}
+
 			// This reader has been canceled and must exit.
 			if t, ok := r.readersCanceled[reader]; ok && t {
 				reader.outputCh <- newData(ringbufStatusEOF, nil)
 				continue
 			}
 
-			if data, ok := reader.read(); ok && data != nil {
+			if data, ok := r.serve(reader); ok {
 				// Remember this as an active reader, serve it with fresh data.
 				r.readersStarving[reader] = false
-				reader.outputCh <- newData(ringbufStatusOK, data)
+				msg := reader.tag(r.serveBatch(reader, data))
+				// Buffered by the reader until passed to its user.
+				items := received(msg)
+				reader.served = append(reader.inflight(), items...)
+				atomic.AddInt64(&reader.buffered, int64(len(items)))
+				reader.outputCh <- msg
+				// Reading might have made room for held back writes.
+				r.unpark()
 				continue
 			}
 
-			if !r.readOnly {
+			if !r.readOnly || len(reader.unacked) > 0 {
 				// This reader is currently starving. Save it so that we can
 				// wake it up when we will get new data.
 				r.readersStarving[reader] = true
 				// Then reply to the reader that we are starving. The reader
 				// will then wait until we wake it up via starving channel.
-				reader.outputCh <- newData(ringbufStatusStarving, nil)
+				// Readers with unacknowledged items are also told when to
+				// come back for a redelivery.
+				reader.outputCh <- newData(ringbufStatusStarving, reader.nextRedelivery())
 			} else {
 				// We are readOnly (there will be no more writes.) The reader
 				// will just get EOF and the reader exits, sending the ReaderCancel
@@ -122,23 +359,88 @@ func (r *Ringbuf) Run() {
 			}
 		case ringbufStatusReaderRequestCancel:
 			reader := msg.data.(*Reader)
-			r.readersCanceled[reader] = true
+			r.cancel(reader)
+
+			// A polling reader might never ask again to learn that it
+			// was canceled. Forget it now, it gets EOF if it does.
+			if _, ok := r.readersStarving[reader]; ok && reader.polling {
+				reader.left = true
+				r.deregister(reader)
+				continue
+			}
 
 			// If the reader being cancelled is starving, rescue it.
 			if r.readersStarving[reader] {
 				r.readersStarving[reader] = false
-				reader.starving <- true
+				reader.wakeup()
+			}
+
+			r.unpark()
+		case ringbufStatusAck:
+			reader := msg.data.(*Reader)
+			delete(reader.unacked, msg.seq)
+
+			// A starving reader might be waiting only for the last
+			// acknowledgements to get EOF.
+			if r.readOnly && len(reader.unacked) == 0 && r.readersStarving[reader] {
+				r.readersStarving[reader] = false
+				reader.wakeup()
 			}
+
+			r.unpark()
+		case ringbufStatusReaderReconfigure:
+			req := msg.data.(*reconfigureRequest)
+			req.responseCh <- r.reconfigure(req.reader, req.opts)
+		case ringbufStatusReaderStats:
+			req := msg.data.(*readerStatsRequest)
+			req.responseCh <- req.reader.stats()
+		case ringbufStatusStats:
+			req := msg.data.(*ringbufStatsRequest)
+			req.responseCh <- r.stats()
+		case ringbufStatusRetained:
+			req := msg.data.(*retainedRequest)
+			req.responseCh <- r.items(r.oldest(), r.written())
+		case ringbufStatusSlice:
+			req := msg.data.(*sliceRequest)
+			req.responseCh <- r.slice(req.from, req.to)
+		case ringbufStatusReaderSeek:
+			req := msg.data.(*seekRequest)
+			req.responseCh <- r.seek(req)
+		case ringbufStatusReaderFork:
+			req := msg.data.(*forkRequest)
+			req.responseCh <- req.reader.fork()
 		// Reader signaling that it has finished reading.
 		case ringbufStatusReaderCancel:
 			// A reader has finished (either because it is cancelled or got EOF from us)
 			// Unregister it from our list of known readers.
 			reader := msg.data.(*Reader)
-			delete(r.readersStarving, reader)
-			delete(r.readersCanceled, reader)
+			r.deregister(reader)
 
 			// Cleanup might take time, do it in the background.
 			go reader.cleanup()
 		}
 	}
 }
+
+// Unsafe. Must be called by IO main loop. Forget a reader that
+// has finished reading.
+func (r *Ringbuf) deregister(reader *Reader) {
+	if _, ok := r.readersStarving[reader]; ok {
+		if reader.options().Lossless {
+			atomic.AddInt32(&r.lossless, -1)
+		}
+
+		r.onReaderLeave(reader)
+	}
+
+	delete(r.readersStarving, reader)
+	delete(r.readersCanceled, reader)
+
+	// Nobody will read what this reader kept.
+	for _, item := range reader.overflow {
+		r.release(item)
+	}
+	reader.overflow = nil
+
+	r.unpark()
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestZeroRingbuf(t *testing.T) {
@@ -134,3 +135,82 @@ func TestReadNoStarve(t *testing.T) {
 
 	ring.Cancel()
 }
+
+func TestReadIdleTimeout(t *testing.T) {
+	ring := NewRingbuf(3)
+	reader := NewReader(ring, WithIdleTimeout(10*time.Millisecond))
+	readCh := reader.ReadCh()
+
+	go ring.Run()
+
+	ring.Write("test0")
+
+	if s := <-readCh; s != "test0" {
+		t.Error("Expected string not found")
+	}
+	if s := <-readCh; s != nil {
+		t.Error("Unexpected read from idle reader")
+	}
+
+	if err := reader.Err(); err != ErrTimeout {
+		t.Error(fmt.Sprintf("Expected timeout error, got %v", err))
+	}
+
+	ring.Cancel()
+}
+
+func TestRingbufStats(t *testing.T) {
+	ring := NewRingbuf(3)
+	go ring.Run()
+
+	for i := 0; i < 5; i++ {
+		ring.Write(i)
+	}
+
+	stats, err := ring.Stats()
+	if err != nil || stats.Written != 5 || stats.Retained != 3 || stats.Readers != 0 {
+		t.Error(fmt.Sprintf("Unexpected stats %+v %v", stats, err))
+	}
+
+	ring.Cancel()
+}
+
+func TestReadIdleTimeoutWakeups(t *testing.T) {
+	ring := NewRingbuf(3)
+	reader := NewReader(ring, WithIdleTimeout(50*time.Millisecond), WithFilter(func(data interface{}) bool {
+		return false
+	}))
+	readCh := reader.ReadCh()
+
+	go ring.Run()
+
+	// Every write wakes the reader up, but it gets nothing.
+	stop := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(5 * time.Millisecond):
+				ring.Write("test")
+			}
+		}
+	}()
+
+	select {
+	case s := <-readCh:
+		if s != nil {
+			t.Error(fmt.Sprintf("Unexpected read from idle reader: '%s'", s))
+		}
+	case <-time.After(time.Second):
+		t.Error("Idle timeout did not fire")
+	}
+
+	close(stop)
+
+	if err := reader.Err(); err != ErrTimeout {
+		t.Error(fmt.Sprintf("Expected timeout error, got %v", err))
+	}
+
+	ring.Cancel()
+}
@@ -0,0 +1,60 @@
+package ringbuf
+
+import "context"
+
+type SearchOptions struct {
+	// Scan from the newest item to the oldest.
+	NewestFirst bool
+	// Stop after this many matches. Zero means no limit.
+	Limit int
+	// Stop scanning when this context is done.
+	Context context.Context
+}
+
+// Returns the retained items for which pred is true. The search runs on
+// a copy of the items taken at once, so writers are not held up meanwhile.
+// If the context is done, the matches found so far are returned with
+// the context's error.
+func (r *Ringbuf) Search(pred func(data interface{}) bool, opts SearchOptions) ([]Item, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	items, err := r.retained()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Item
+
+	for i := range items {
+		// Checking the context for each item would cost more than the search.
+		if i%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return found, err
+			}
+		}
+
+		item := items[i]
+		if opts.NewestFirst {
+			item = items[len(items)-1-i]
+		}
+
+		if !pred(item.Data) {
+			continue
+		}
+
+		found = append(found, item)
+
+		if opts.Limit > 0 && len(found) >= opts.Limit {
+			break
+		}
+	}
+
+	return found, nil
+}
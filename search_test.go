@@ -0,0 +1,40 @@
+package ringbuf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRingbufSearch(t *testing.T) {
+	ring := NewRingbuf(10)
+	go ring.Run()
+
+	for i := 0; i < 15; i++ {
+		ring.Write(i)
+	}
+
+	even := func(data interface{}) bool {
+		return data.(int)%2 == 0
+	}
+
+	items, err := ring.Search(even, SearchOptions{})
+	if err != nil || fmt.Sprint(items) != "[{6 6} {8 8} {10 10} {12 12} {14 14}]" {
+		t.Error(fmt.Sprintf("Unexpected result %v %v", items, err))
+	}
+
+	items, err = ring.Search(even, SearchOptions{NewestFirst: true, Limit: 2})
+	if err != nil || fmt.Sprint(items) != "[{14 14} {12 12}]" {
+		t.Error(fmt.Sprintf("Unexpected result %v %v", items, err))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := ring.Search(even, SearchOptions{Context: ctx}); !errors.Is(err, context.Canceled) {
+		t.Error(fmt.Sprintf("Expected context.Canceled, got %v", err))
+	}
+
+	ring.Cancel()
+}
@@ -0,0 +1,210 @@
+package ringbuf
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+type seekRequest struct {
+	reader     *Reader
+	seq        int64
+	rewind     bool // seq is relative to where the user is
+	responseCh chan error
+}
+
+type forkRequest struct {
+	reader     *Reader
+	responseCh chan *Reader
+}
+
+// Continue reading from the item with sequence number seq. Items the
+// reader has received already, but not passed to the user yet, are
+// dropped. Fails with ErrOverwritten if the sequence number is not
+// retained any more, or with ErrNotWritten if it is past the next
+// write. Not called Seek(), as it is not an io.Seeker.
+func (r *Reader) SeekTo(seq int64) error {
+	return r.seek(&seekRequest{reader: r, seq: seq})
+}
+
+// Go back n items from the last one passed to the user, to read them again.
+func (r *Reader) Rewind(n int64) error {
+	r.settle()
+	return r.seek(&seekRequest{reader: r, seq: n, rewind: true})
+}
+
+func (r *Reader) seek(req *seekRequest) error {
+	req.responseCh = make(chan error, 1)
+
+	if err := r.ring.send(newData(ringbufStatusReaderSeek, req)); err != nil {
+		return err
+	}
+
+	if err := <-req.responseCh; err != nil {
+		return err
+	}
+
+	// The reading goroutine might be offering an item from before.
+	r.settle()
+
+	return nil
+}
+
+// Create a new reader with the same options, positioned after the last
+// item passed to the user of this one. The new reader is independent
+// and starts with ReadCh().
+func (r *Reader) Fork() (*Reader, error) {
+	r.settle()
+
+	req := &forkRequest{reader: r, responseCh: make(chan *Reader, 1)}
+
+	if err := r.ring.send(newData(ringbufStatusReaderFork, req)); err != nil {
+		return nil, err
+	}
+
+	return <-req.responseCh, nil
+}
+
+// Record that the item seq is about to be passed to the user.
+func (r *Reader) offer(seq int64) {
+	atomic.StoreInt64(&r.offering, seq+1)
+}
+
+// Record that count items will never be passed to the user.
+func (r *Reader) withdraw(count int) {
+	atomic.AddInt64(&r.buffered, -int64(count))
+	atomic.StoreInt64(&r.offering, 0)
+}
+
+// True if msg was served before the last seek.
+func (r *Reader) stale(msg Data) bool {
+	return msg.gen != atomic.LoadInt64(&r.seeks)
+}
+
+// Unsafe. Must be called by IO main loop. Mark msg, and all items of
+// a batch, with the seeks so far.
+func (r *Reader) tag(msg Data) Data {
+	msg.gen = atomic.LoadInt64(&r.seeks)
+
+	if msg.status == ringbufStatusBatch {
+		for i := range msg.data.([]Data) {
+			msg.data.([]Data)[i].gen = msg.gen
+		}
+	}
+
+	return msg
+}
+
+// Record that the item seq has been passed to the user.
+func (r *Reader) passed(seq int64) {
+	atomic.AddInt64(&r.buffered, -1)
+	atomic.StoreInt64(&r.next, seq+1)
+	atomic.StoreInt64(&r.offering, 0)
+}
+
+// Wait until the reading goroutine is not in the middle of recording
+// that the user got an item, so that position() is exact. Once this
+// returns, the goroutine looks at the seeks again before offering.
+func (r *Reader) settle() {
+	for atomic.LoadInt64(&r.offering) != 0 {
+		select {
+		case r.probeCh <- struct{}{}:
+			// The user has not got the item yet.
+			return
+		default:
+			runtime.Gosched()
+		}
+	}
+}
+
+// Unsafe. Must be called by IO main loop. Items served to the reader
+// that its user has not got yet, in order.
+func (r *Reader) inflight() []Data {
+	n := int(atomic.LoadInt64(&r.buffered))
+	if n > len(r.served) {
+		n = len(r.served)
+	}
+
+	return r.served[len(r.served)-n:]
+}
+
+// Unsafe. Must be called by IO main loop. The sequence number of the
+// next item for the user, after the last one passed to it.
+func (r *Reader) position() int64 {
+	if inflight := r.inflight(); len(inflight) > 0 {
+		return inflight[0].seq
+	}
+
+	if next := atomic.LoadInt64(&r.next); next >= 0 {
+		return next
+	}
+
+	return r.seq()
+}
+
+// Unsafe. Must be called by IO main loop.
+func (r *Ringbuf) seek(req *seekRequest) error {
+	reader := req.reader
+	seq := req.seq
+
+	if req.rewind {
+		seq = reader.position() - req.seq
+	}
+
+	switch {
+	case seq < r.oldest():
+		return ErrOverwritten
+	case seq > r.written():
+		return ErrNotWritten
+	}
+
+	// The new position replaces any pending snapshot and overflow.
+	reader.started = true
+	reader.snapshot = nil
+	reader.replay = nil
+
+	for _, item := range reader.overflow {
+		r.release(item)
+	}
+	reader.overflow = nil
+	// Nothing to acknowledge for items that will never be passed on.
+	for _, msg := range reader.inflight() {
+		delete(reader.unacked, msg.seq)
+	}
+
+	reader.served = nil
+	reader.setSeq(seq)
+	atomic.StoreInt64(&reader.next, seq)
+	// What the reader has received already is dropped.
+	atomic.AddInt64(&reader.seeks, 1)
+
+	if r.readersStarving[reader] {
+		r.readersStarving[reader] = false
+		reader.wakeup()
+	}
+
+	return nil
+}
+
+// Unsafe. Must be called by IO main loop.
+func (r *Reader) fork() *Reader {
+	fork := newReader(r.ring, r.options())
+
+	fork.pos, fork.cycles = r.pos, r.cycles
+	fork.started = r.started
+	fork.snapshot = append([]Item(nil), r.snapshot...)
+
+	// The fork starts with what the user of r has not got yet.
+	for _, msg := range r.inflight() {
+		fork.replay = append(fork.replay, Item{Seq: msg.seq, Data: msg.data})
+	}
+
+	fork.replay = append(fork.replay, r.replay...)
+	fork.overflow = append([]Item(nil), r.overflow...)
+
+	// The fork keeps the overwritten items too.
+	for _, item := range fork.overflow {
+		r.ring.kept[item.Seq]++
+	}
+
+	return fork
+}
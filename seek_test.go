@@ -0,0 +1,166 @@
+package ringbuf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Wait up to a second for cond to be true.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(fmt.Sprintf("Timed out waiting for %s", what))
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReaderSeekRewind(t *testing.T) {
+	ring := NewRingbuf(5)
+	go ring.Run()
+
+	for i := 0; i < 7; i++ {
+		ring.Write(i)
+	}
+
+	reader := NewReader(ring)
+
+	if err := reader.SeekTo(1); !errors.Is(err, ErrOverwritten) {
+		t.Error(fmt.Sprintf("Expected ErrOverwritten, got %v", err))
+	}
+
+	if err := reader.SeekTo(8); !errors.Is(err, ErrNotWritten) {
+		t.Error(fmt.Sprintf("Expected ErrNotWritten, got %v", err))
+	}
+
+	if err := reader.SeekTo(4); err != nil {
+		t.Error(fmt.Sprintf("Unexpected error: %v", err))
+	}
+
+	readCh := reader.ReadCh()
+
+	for i := 4; i < 7; i++ {
+		if data := <-readCh; data != i {
+			t.Error(fmt.Sprintf("Expected %d, got %v", i, data))
+		}
+	}
+
+	if err := reader.Rewind(2); err != nil {
+		t.Error(fmt.Sprintf("Unexpected error: %v", err))
+	}
+
+	for i := 5; i < 7; i++ {
+		if data := <-readCh; data != i {
+			t.Error(fmt.Sprintf("Expected %d after rewinding, got %v", i, data))
+		}
+	}
+
+	if err := reader.Rewind(6); !errors.Is(err, ErrOverwritten) {
+		t.Error(fmt.Sprintf("Expected ErrOverwritten, got %v", err))
+	}
+
+	reader.Cancel()
+	for range readCh {
+	}
+
+	ring.Cancel()
+}
+
+func TestReaderFork(t *testing.T) {
+	ring := NewRingbuf(10)
+	go ring.Run()
+
+	for i := 0; i < 4; i++ {
+		ring.Write(i)
+	}
+
+	reader := NewReader(ring)
+	readCh := reader.ReadCh()
+
+	for i := 0; i < 2; i++ {
+		<-readCh
+	}
+
+	// The reader has the next item, but its user has not got it.
+	waitFor(t, "the reader to get item 2", func() bool {
+		stats, _ := reader.Stats()
+		return stats.Delivered == 3
+	})
+
+	fork, err := reader.Fork()
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Unexpected error: %v", err))
+	}
+
+	ring.EOF()
+
+	var forked []interface{}
+	for data := range fork.All() {
+		forked = append(forked, data)
+	}
+
+	if fmt.Sprint(forked) != "[2 3]" {
+		t.Error(fmt.Sprintf("Expected the fork to continue from 2, got %v", forked))
+	}
+
+	if data := <-readCh; data != 2 {
+		t.Error(fmt.Sprintf("Expected the original to continue with 2, got %v", data))
+	}
+
+	for range readCh {
+	}
+
+	ring.Cancel()
+}
+
+func TestReaderSeekMidStream(t *testing.T) {
+	same := func(data interface{}) interface{} {
+		return data
+	}
+
+	for _, opt := range []ReaderOption{WithMaxBatch(1), WithMaxBatch(3), WithConflate(same)} {
+		ring := NewRingbuf(10)
+		go ring.Run()
+
+		for i := 0; i < 5; i++ {
+			ring.Write(i)
+		}
+
+		reader := NewReader(ring, opt)
+		readCh := reader.ReadCh()
+
+		for i := 0; i < 2; i++ {
+			<-readCh
+		}
+
+		// The reader has fetched more than the user got.
+		if err := reader.Rewind(2); err != nil {
+			t.Error(fmt.Sprintf("Unexpected error: %v", err))
+		}
+
+		for i := 0; i < 2; i++ {
+			if data := <-readCh; data != i {
+				t.Error(fmt.Sprintf("Expected %d after rewinding, got %v", i, data))
+			}
+		}
+
+		if err := reader.SeekTo(4); err != nil {
+			t.Error(fmt.Sprintf("Unexpected error: %v", err))
+		}
+
+		if data := <-readCh; data != 4 {
+			t.Error(fmt.Sprintf("Expected 4 after seeking, got %v", data))
+		}
+
+		reader.Cancel()
+		for range readCh {
+		}
+
+		ring.Cancel()
+	}
+}
@@ -0,0 +1,122 @@
+package ringbuf
+
+// What happens when the writer overtakes a reader.
+type SlowReaderPolicy int
+
+const (
+	// Skip to the start of the ringbuf (default.)
+	SlowReaderSkip SlowReaderPolicy = iota
+	// Skip to the newest item in the ringbuf.
+	SlowReaderSkipToNewest
+	// Stop the reader; its Err() is an *ErrOverrun.
+	SlowReaderDisconnect
+	// Keep up to SlowReaderBuffer overwritten items for the reader,
+	// then skip to the oldest item in the ringbuf.
+	SlowReaderBuffer
+)
+
+// Unsafe. Must be called by IO main loop. Report that reader has lost
+// dropped items because it was too slow.
+func (r *Ringbuf) slowReader(reader *Reader, dropped int64) {
+	if f := reader.options().OnSlow; f != nil {
+		r.hookQueue.push(func() { f(reader, dropped) })
+	}
+
+	if r.hooks != nil && r.hooks.OnOverrun != nil {
+		f := r.hooks.OnOverrun
+		r.hookQueue.push(func() { f(reader, dropped) })
+	}
+}
+
+// Unsafe. Must be called by IO main loop. The writer is about to
+// overwrite the item seq: keep it for readers that still need it.
+func (r *Ringbuf) evict(seq int64) {
+	item := Item{Seq: seq, Data: r.data[seq%r.size]}
+	holders := 0
+
+	for reader := range r.readersStarving {
+		if reader.options().SlowReader != SlowReaderBuffer || reader.seq() > seq {
+			continue
+		}
+
+		if len(reader.overflow) >= reader.options().SlowReaderBuffer {
+			if len(reader.overflow) == 0 {
+				continue
+			}
+
+			dropped := reader.overflow[0]
+			reader.overflow[0], reader.overflow = Item{}, reader.overflow[1:]
+			reader.dropped++
+			r.slowReader(reader, 1)
+			r.release(dropped)
+		}
+
+		reader.overflow = append(reader.overflow, item)
+		holders++
+	}
+
+	if holders == 0 {
+		r.onEvict(item.Data)
+		return
+	}
+
+	if r.kept == nil {
+		r.kept = make(map[int64]int)
+	}
+
+	r.kept[seq] = holders
+}
+
+// Unsafe. Must be called by IO main loop. A reader is done with an
+// overwritten item it kept; the last one to be done evicts it.
+func (r *Ringbuf) release(item Item) {
+	r.kept[item.Seq]--
+	if r.kept[item.Seq] > 0 {
+		return
+	}
+
+	delete(r.kept, item.Seq)
+	r.onEvict(item.Data)
+}
+
+// Unsafe. Must be called by IO main loop. Returns the oldest item
+// kept for this reader after it was overwritten.
+func (r *Reader) nextOverflow() (Item, bool) {
+	if len(r.overflow) == 0 {
+		return Item{}, false
+	}
+
+	item := r.overflow[0]
+	r.overflow[0], r.overflow = Item{}, r.overflow[1:]
+	r.setSeq(item.Seq + 1)
+	r.ring.release(item)
+
+	return item, true
+}
+
+// Unsafe. Must be called by IO main loop. Apply the SlowReader policy
+// to a reader that the writer has overtaken. Returns the message to
+// send to the reader, if any.
+func (r *Ringbuf) overrun(reader *Reader) (Data, bool) {
+	dropped := r.oldest() - reader.seq()
+
+	switch reader.options().SlowReader {
+	case SlowReaderSkipToNewest:
+		dropped = r.written() - 1 - reader.seq()
+		reader.setSeq(r.written() - 1)
+	case SlowReaderDisconnect:
+		reader.err = &ErrOverrun{Dropped: dropped}
+		r.cancel(reader)
+	default:
+		reader.setSeq(r.oldest())
+	}
+
+	reader.dropped += dropped
+	r.slowReader(reader, dropped)
+
+	if reader.options().SlowReader == SlowReaderDisconnect {
+		return newData(ringbufStatusEOF, nil), true
+	}
+
+	return Data{}, false
+}
@@ -0,0 +1,168 @@
+package ringbuf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Read one item, then let the writer overtake the reader.
+func helperOvertake(t *testing.T, opts ...ReaderOption) (*Ringbuf, *Reader, <-chan interface{}) {
+	ring := NewRingbuf(3)
+	reader := NewReader(ring, opts...)
+	readCh := reader.ReadCh()
+
+	go ring.Run()
+
+	ring.Write("test0")
+
+	if data := <-readCh; data != "test0" {
+		t.Error(fmt.Sprintf("Expected value test0, got '%s'", data))
+	}
+
+	for i := 1; i <= 5; i++ {
+		ring.Write(fmt.Sprintf("test%d", i))
+	}
+	ring.EOF()
+
+	return ring, reader, readCh
+}
+
+func TestSlowReaderDisconnect(t *testing.T) {
+	slowCh := make(chan int64, 1)
+
+	ring, reader, readCh := helperOvertake(t,
+		WithSlowReader(SlowReaderDisconnect, 0),
+		WithOnSlow(func(reader *Reader, dropped int64) {
+			slowCh <- dropped
+		}))
+
+	for data := range readCh {
+		// The reader might have fetched this before being overtaken.
+		if data != "test1" {
+			t.Error(fmt.Sprintf("Unexpected read from disconnected reader: '%s'", data))
+		}
+	}
+
+	if err, ok := reader.Err().(*ErrOverrun); !ok || err.Dropped <= 0 {
+		t.Error(fmt.Sprintf("Expected overrun error, got %v", reader.Err()))
+	}
+
+	if dropped := <-slowCh; dropped <= 0 {
+		t.Error(fmt.Sprintf("Expected dropped items to be reported, got %d", dropped))
+	}
+
+	ring.Cancel()
+}
+
+func TestSlowReaderSkipToNewest(t *testing.T) {
+	ring, _, readCh := helperOvertake(t, WithSlowReader(SlowReaderSkipToNewest, 0))
+
+	var last interface{}
+	n := 0
+
+	for data := range readCh {
+		last = data
+		n++
+	}
+
+	if last != "test5" || n > 2 {
+		t.Error(fmt.Sprintf("Expected to skip to test5, read %d items ending with '%s'", n, last))
+	}
+
+	ring.Cancel()
+}
+
+func TestSlowReaderBuffer(t *testing.T) {
+	ring, reader, readCh := helperOvertake(t, WithSlowReader(SlowReaderBuffer, 10))
+
+	i := 1
+
+	for data := range readCh {
+		if exp := fmt.Sprintf("test%d", i); data != exp {
+			t.Error(fmt.Sprintf("Expected value %s, got '%s'", exp, data))
+		}
+		i++
+	}
+
+	if i != 6 {
+		t.Error(fmt.Sprintf("Expected to read up to test5, stopped at test%d", i-1))
+	}
+
+	if stats, _ := reader.Stats(); stats.Dropped != 0 {
+		t.Error(fmt.Sprintf("Expected no dropped items, got %d", stats.Dropped))
+	}
+
+	ring.Cancel()
+}
+
+func TestSlowReaderSkip(t *testing.T) {
+	ring := NewRingbuf(3)
+	reader := NewReader(ring)
+
+	if _, ok := ring.serve(reader); ok {
+		t.Error("Expected read fail")
+	}
+
+	for i := 0; i < 10; i++ {
+		ring.write(fmt.Sprintf("test%d", i))
+	}
+
+	// Lapped, but the oldest items are there to read.
+	for i := 7; i < 10; i++ {
+		if msg, ok := ring.serve(reader); !ok || msg.data != fmt.Sprintf("test%d", i) {
+			t.Error(fmt.Sprintf("Expected value test%d, got '%s'", i, msg.data))
+		}
+	}
+
+	if reader.dropped != 7 {
+		t.Error(fmt.Sprintf("Expected 7 dropped items, got %d", reader.dropped))
+	}
+}
+
+func TestSlowReaderBufferEvict(t *testing.T) {
+	for _, buffer := range []int{1, 10} {
+		evictCh := make(chan interface{}, 10)
+
+		ring := NewRingbuf(3, WithHooks(&Hooks{
+			OnEvict: func(data interface{}) {
+				evictCh <- data
+			},
+		}))
+		reader := NewReader(ring, WithSlowReader(SlowReaderBuffer, buffer))
+		readCh := reader.ReadCh()
+
+		go ring.Run()
+
+		ring.Write("test0")
+		<-readCh
+
+		for i := 1; i <= 6; i++ {
+			ring.Write(fmt.Sprintf("test%d", i))
+		}
+		ring.EOF()
+
+		for range readCh {
+		}
+
+		// Every overwritten item is evicted once, kept or not.
+		evicted := make(map[interface{}]bool)
+
+		for i := 0; i < 4; i++ {
+			select {
+			case data := <-evictCh:
+				evicted[data] = true
+			case <-time.After(time.Second):
+				t.Fatal(fmt.Sprintf("Buffer %d: expected 4 evicted items, got %v", buffer, evicted))
+			}
+		}
+
+		for i := 0; i < 4; i++ {
+			if exp := fmt.Sprintf("test%d", i); !evicted[exp] {
+				t.Error(fmt.Sprintf("Buffer %d: expected %s to be evicted, got %v", buffer, exp, evicted))
+			}
+		}
+
+		ring.Cancel()
+	}
+}
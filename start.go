@@ -0,0 +1,50 @@
+package ringbuf
+
+// Where a new reader starts reading.
+type StartPosition int
+
+const (
+	// From the beginning, or the snapshot of a compacting ringbuf.
+	StartOldest StartPosition = iota
+	// Only items written after the reader joins.
+	StartNewest
+	// From ReaderOptions.StartSeq, or the nearest retained item.
+	StartSeq
+)
+
+// Unsafe. Must be called by IO main loop on the first request of reader.
+func (r *Ringbuf) start(reader *Reader) {
+	reader.started = true
+
+	switch opts := reader.options(); opts.Start {
+	case StartNewest:
+		reader.setSeq(r.written())
+	case StartSeq:
+		seq := opts.StartSeq
+		if seq < r.oldest() {
+			seq = r.oldest()
+		}
+		if seq > r.written() {
+			seq = r.written()
+		}
+
+		reader.setSeq(seq)
+	default:
+		// New readers of a compacting ringbuf start from its snapshot.
+		if r.key != nil && reader.seq() == 0 {
+			reader.snapshot = r.snapshot()
+			reader.setSeq(r.written())
+		}
+	}
+
+	// A new reader is not slow, even if it starts behind.
+	if reader.options().SlowReader != SlowReaderSkip && reader.seq() < r.oldest() {
+		reader.setSeq(r.oldest())
+	}
+}
+
+// The reader wants data, unless its filter says otherwise.
+func (r *Reader) wants(data interface{}) bool {
+	f := r.options().Filter
+	return f == nil || f(data)
+}
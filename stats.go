@@ -0,0 +1,93 @@
+package ringbuf
+
+import "sync/atomic"
+
+// Counters of a Reader.
+type ReaderStats struct {
+	Delivered int64 // Items served by the ringbuf
+	Lag       int64 // Items not yet delivered to the user
+	Dropped   int64 // Items lost because the writer overtook the reader
+	Conflated int64 // Items replaced by a newer item with the same key
+}
+
+type readerStatsRequest struct {
+	reader     *Reader
+	responseCh chan ReaderStats
+}
+
+// Get the current counters of this reader from the ringbuf.
+func (r *Reader) Stats() (ReaderStats, error) {
+	req := &readerStatsRequest{
+		reader:     r,
+		responseCh: make(chan ReaderStats, 1),
+	}
+
+	if err := r.ring.send(newData(ringbufStatusReaderStats, req)); err != nil {
+		return ReaderStats{}, err
+	}
+
+	return <-req.responseCh, nil
+}
+
+// Unsafe. Must be called by IO main loop.
+func (r *Reader) stats() ReaderStats {
+	// What has been overwritten will never be delivered.
+	lag := r.ring.written() - r.seq()
+	if lag > r.ring.size {
+		lag = r.ring.size
+	}
+
+	lag += int64(len(r.snapshot)) + atomic.LoadInt64(&r.buffered)
+
+	return ReaderStats{
+		Delivered: r.delivered,
+		Lag:       lag,
+		Dropped:   r.dropped,
+		Conflated: atomic.LoadInt64(&r.conflated),
+	}
+}
+
+// Counters of a Ringbuf.
+type RingbufStats struct {
+	Written  int64 // Items written since the start
+	Retained int64 // Items that can still be read
+	Readers  int   // Readers currently reading
+	Lag      int64 // Lag of the slowest reader, see ReaderStats
+}
+
+type ringbufStatsRequest struct {
+	responseCh chan RingbufStats
+}
+
+// Get the current counters of the ringbuf.
+func (r *Ringbuf) Stats() (RingbufStats, error) {
+	req := &ringbufStatsRequest{responseCh: make(chan RingbufStats, 1)}
+
+	if err := r.send(newData(ringbufStatusStats, req)); err != nil {
+		return RingbufStats{}, err
+	}
+
+	return <-req.responseCh, nil
+}
+
+// Unsafe. Must be called by IO main loop.
+func (r *Ringbuf) stats() RingbufStats {
+	stats := RingbufStats{
+		Written:  r.written(),
+		Retained: r.written() - r.oldest(),
+	}
+
+	for reader := range r.readersStarving {
+		if r.readersCanceled[reader] {
+			continue
+		}
+
+		stats.Readers++
+
+		if lag := reader.stats().Lag; lag > stats.Lag {
+			stats.Lag = lag
+		}
+	}
+
+	return stats
+}
@@ -10,6 +10,18 @@ const (
 	ringbufStatusReaderCancel
 	ringbufStatusWrite
 	ringbufStatusWriteOrStarve
+	ringbufStatusRequeue
+	ringbufStatusAck
+	ringbufStatusWriteWait
+	ringbufStatusReaderStats
+	ringbufStatusBatch
+	ringbufStatusReaderReconfigure
+	ringbufStatusTryWrite
+	ringbufStatusRetained
+	ringbufStatusSlice
+	ringbufStatusReaderSeek
+	ringbufStatusReaderFork
+	ringbufStatusStats
 )
 
 type ringbufStatus int
@@ -17,8 +29,14 @@ type ringbufStatus int
 type Data struct {
 	data   interface{}
 	status ringbufStatus
+	seq    int64
+	gen    int64 // Seeks of the reader it was served to, see Reader.stale()
 }
 
 func newData(status ringbufStatus, data interface{}) Data {
 	return Data{data: data, status: status}
 }
+
+func newDataSeq(status ringbufStatus, data interface{}, seq int64) Data {
+	return Data{data: data, status: status, seq: seq}
+}
@@ -0,0 +1,139 @@
+package ringbuf
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Write data only if the ringbuf can take it right away. Returns false
+// if the main loop is busy, lossless readers hold the write back, or
+// writing has finished.
+func (r *Ringbuf) TryWrite(data interface{}) bool {
+	if atomic.LoadInt32(&r.closed) != 0 {
+		return false
+	}
+
+	if atomic.LoadInt32(&r.lossless) == 0 {
+		select {
+		case r.dataCh <- newData(ringbufStatusWrite, data):
+			return true
+		default:
+			return false
+		}
+	}
+
+	// Lossless readers might refuse the write, the main loop
+	// tells us without keeping it.
+	responseCh := make(chan bool, 1)
+	w := &Write{data: data, responseCh: responseCh}
+
+	select {
+	case r.dataCh <- newData(ringbufStatusTryWrite, w):
+		return <-responseCh
+	default:
+		return false
+	}
+}
+
+// Unsafe. Must be called by IO main loop.
+func (r *Ringbuf) tryWrite(w *Write) bool {
+	if r.readOnly || len(r.parked) > 0 || r.full() {
+		return false
+	}
+
+	r.write(w.data)
+	r.wakeupStarving()
+
+	return true
+}
+
+// Read the next item if there is one, without waiting. Returns false if
+// the main loop is busy or has no new data, and an error once reading
+// has stopped: io.EOF at the end of data, otherwise the same as Err().
+// The item comes with its sequence number, for readers in Ack mode.
+// Do not mix with ReadCh() or ReadItemCh().
+func (r *Reader) TryNext() (Item, bool, error) {
+	if r.finished {
+		return Item{}, false, r.finishErr()
+	}
+
+	r.updateLimiter()
+
+	if r.limiter != nil && r.limiter.delay(time.Now()) > 0 {
+		return Item{}, false, nil
+	}
+
+	// Items received before a seek are dropped.
+	for len(r.polled) > 0 && r.stale(r.polled[0]) {
+		r.polled[0], r.polled = Data{}, r.polled[1:]
+		r.withdraw(1)
+	}
+
+	if len(r.polled) == 0 {
+		joined := r.joined
+		if !joined {
+			// Nothing reads for us after Cancel(), the main loop
+			// has to know. Set before the ringbuf ever sees us.
+			r.polling = true
+			r.join()
+		}
+
+		select {
+		case r.ring.dataCh <- newData(ringbufStatusReader, r):
+		case <-r.ring.done:
+			r.err = ErrClosed
+			return r.finish()
+		default:
+			// The ringbuf has not seen us, it must not wait for us.
+			if !joined {
+				r.joined = false
+				atomic.AddInt32(&r.ring.joining, -1)
+			}
+
+			return Item{}, false, nil
+		}
+
+		msg := <-r.outputCh
+
+		switch msg.status {
+		case ringbufStatusOK, ringbufStatusBatch:
+			r.polled = received(msg)
+		case ringbufStatusEOF:
+			return r.finish()
+		case ringbufStatusStarving:
+			if r.options().NoStarve {
+				return r.finish()
+			}
+
+			return Item{}, false, nil
+		}
+	}
+
+	msg := r.polled[0]
+	r.polled[0], r.polled = Data{}, r.polled[1:]
+
+	if r.limiter != nil {
+		r.limiter.take(time.Now())
+	}
+
+	r.passed(msg.seq)
+
+	return Item{Seq: msg.seq, Data: msg.data}, true, nil
+}
+
+// Stop polling and leave the ringbuf.
+func (r *Reader) finish() (Item, bool, error) {
+	r.finished = true
+	r.leave()
+
+	return Item{}, false, r.finishErr()
+}
+
+func (r *Reader) finishErr() error {
+	if r.err != nil {
+		return r.err
+	}
+
+	return io.EOF
+}
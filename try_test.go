@@ -0,0 +1,144 @@
+package ringbuf
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTryWriteTryNext(t *testing.T) {
+	ring := NewRingbuf(10)
+
+	// Nobody is running the ringbuf, nothing can be written.
+	if ring.TryWrite("test0") {
+		t.Error("Expected TryWrite to fail without Run()")
+	}
+
+	go ring.Run()
+
+	reader := NewReader(ring)
+
+	if _, ok, err := reader.TryNext(); ok || err != nil {
+		t.Errorf("Expected no data and no error, got %v %v", ok, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		// The main loop might be busy for a moment.
+		for !ring.TryWrite(i) {
+		}
+	}
+
+	for i := 0; i < 3; {
+		item, ok, err := reader.TryNext()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !ok {
+			continue
+		}
+
+		if item.Data != i || item.Seq != int64(i) {
+			t.Errorf("Expected %d, got %+v", i, item)
+		}
+		i++
+	}
+
+	ring.EOF()
+
+	if ring.TryWrite("test1") {
+		t.Error("Expected TryWrite to fail after EOF")
+	}
+
+	for {
+		if _, _, err := reader.TryNext(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Errorf("Expected io.EOF, got %v", err)
+			}
+			break
+		}
+	}
+
+	ring.Cancel()
+}
+
+func TestTryWriteLossless(t *testing.T) {
+	ring := NewRingbuf(2)
+	go ring.Run()
+
+	reader := NewReader(ring, WithLossless())
+
+	for i := 0; i < 2; i++ {
+		for !ring.TryWrite(i) {
+		}
+	}
+
+	// The reader joins reading the first item.
+	for {
+		if _, ok, _ := reader.TryNext(); ok {
+			break
+		}
+	}
+
+	for !ring.TryWrite(2) {
+	}
+
+	// The second item is not read yet, it can't be overwritten.
+	for i := 0; i < 10; i++ {
+		if ring.TryWrite(3) {
+			t.Fatal("Expected TryWrite to fail while a lossless reader is behind")
+		}
+	}
+
+	// The canceled reader never polls again, it must not keep Run() going.
+	reader.Cancel()
+	ring.Cancel()
+
+	select {
+	case <-ring.done:
+	case <-time.After(time.Second):
+		t.Error("Run() did not return after the polling reader was canceled")
+	}
+}
+
+func TestTryNextAck(t *testing.T) {
+	ring := NewRingbuf(3)
+	go ring.Run()
+
+	reader := NewReader(ring, WithAck(time.Hour))
+
+	ring.Write("test0")
+	ring.EOF()
+
+	var item Item
+	for {
+		next, ok, err := reader.TryNext()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if ok {
+			item = next
+			break
+		}
+	}
+
+	if item.Data != "test0" {
+		t.Errorf("Expected test0, got %v", item.Data)
+	}
+
+	// Only acknowledged, the reader gets EOF.
+	reader.Ack(item.Seq)
+
+	for {
+		if _, _, err := reader.TryNext(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Errorf("Expected io.EOF, got %v", err)
+			}
+			break
+		}
+	}
+
+	ring.Cancel()
+}